@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"os"
+
+	pl2 "github.com/muitdebos/pl2/pkg"
+)
+
+type options struct {
+	pl2       *string
+	sprite    *string
+	transform *string
+	out       *string
+}
+
+func parseOptions(o *options) (terminate bool) {
+	o.pl2 = flag.String("pl2", "", "input pl2 file (required)")
+	o.sprite = flag.String("png", "", "indexed png sprite, its pixels are indices into the pl2 base palette (required)")
+	o.transform = flag.String("transform", "", "transform applied to the sprite, eg. LightLevelVariations[12], HueVariations[30], RedTones, a catalog name like hue-shift-75-dark or a colors.txt code like cblu (required)")
+	o.out = flag.String("out", "./output.png", "path to the output png file")
+
+	flag.Parse()
+
+	return *o.pl2 == "" || *o.sprite == "" || *o.transform == ""
+}
+
+func main() {
+	o := &options{}
+
+	if parseOptions(o) {
+		flag.Usage()
+		return
+	}
+
+	data, err := ioutil.ReadFile(*o.pl2)
+	if err != nil {
+		log.Fatal(fmt.Errorf("could not read file, %w", err))
+	}
+
+	p, err := pl2.FromBytes(data)
+	if err != nil {
+		log.Fatal(fmt.Errorf("could not decode %s, %w", *o.pl2, err))
+	}
+
+	sprite, err := readSprite(*o.sprite)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	recolored, err := pl2.Recolor(sprite, p, *o.transform)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := writeImage(*o.out, recolored); err != nil {
+		log.Fatal(fmt.Errorf("problem writing image, %w", err))
+	}
+}
+
+func readSprite(path string) (*image.Paletted, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read sprite, %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s, %w", path, err)
+	}
+
+	sprite, ok := img.(*image.Paletted)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an indexed png", path)
+	}
+
+	return sprite, nil
+}
+
+func writeImage(outPath string, img image.Image) error {
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+
+	if err = png.Encode(f, img); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
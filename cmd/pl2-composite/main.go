@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"os"
+
+	pl2 "github.com/muitdebos/pl2/pkg"
+)
+
+type options struct {
+	pl2  *string
+	src  *string
+	dst  *string
+	mode *string
+	out  *string
+}
+
+func parseOptions(o *options) (terminate bool) {
+	o.pl2 = flag.String("pl2", "", "input pl2 file (required)")
+	o.src = flag.String("src", "", "indexed png drawn over the destination, eg. a spell overlay (required)")
+	o.dst = flag.String("dst", "", "indexed png the source is drawn over (required)")
+	o.mode = flag.String("mode", "alpha50", "blend mode, alpha25, alpha50, alpha75, additive, multiplicative or max")
+	o.out = flag.String("out", "./output.png", "path to the output png file, the blend tables render on the left and the true color reference on the right")
+
+	flag.Parse()
+
+	return *o.pl2 == "" || *o.src == "" || *o.dst == ""
+}
+
+func main() {
+	o := &options{}
+
+	if parseOptions(o) {
+		flag.Usage()
+		return
+	}
+
+	mode, err := pl2.ParseBlendMode(*o.mode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(*o.pl2)
+	if err != nil {
+		log.Fatal(fmt.Errorf("could not read file, %w", err))
+	}
+
+	p, err := pl2.FromBytes(data)
+	if err != nil {
+		log.Fatal(fmt.Errorf("could not decode %s, %w", *o.pl2, err))
+	}
+
+	src, err := readIndexed(*o.src)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dst, err := readIndexed(*o.dst)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	blended, err := pl2.Composite(src, dst, p, mode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	reference, err := pl2.CompositeReference(src, dst, p, mode)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := writeImage(*o.out, sideBySide(blended, reference)); err != nil {
+		log.Fatal(fmt.Errorf("problem writing image, %w", err))
+	}
+}
+
+// sideBySide returns an image with left on the left, and right on the right.
+func sideBySide(left, right image.Image) image.Image {
+	lb, rb := left.Bounds(), right.Bounds()
+
+	height := lb.Dy()
+	if rb.Dy() > height {
+		height = rb.Dy()
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, lb.Dx()+rb.Dx(), height))
+
+	draw.Draw(img, image.Rect(0, 0, lb.Dx(), lb.Dy()), left, lb.Min, draw.Src)
+	draw.Draw(img, image.Rect(lb.Dx(), 0, lb.Dx()+rb.Dx(), rb.Dy()), right, rb.Min, draw.Src)
+
+	return img
+}
+
+func readIndexed(path string) (*image.Paletted, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read image, %w", err)
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s, %w", path, err)
+	}
+
+	indexed, ok := img.(*image.Paletted)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an indexed png", path)
+	}
+
+	return indexed, nil
+}
+
+func writeImage(outPath string, img image.Image) error {
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+
+	if err = png.Encode(f, img); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	pl2 "github.com/muitdebos/pl2/pkg"
+)
+
+type options struct {
+	reference *string
+	generated *string
+	worst     *int
+}
+
+func parseOptions(o *options) (terminate bool) {
+	o.reference = flag.String("ref", "", "reference pl2 file, like the act1 pal.pl2 of the game (required)")
+	o.generated = flag.String("pl2", "", "generated pl2 file (required)")
+	o.worst = flag.Int("worst", 5, "number of worst transforms listed for each section, not negative")
+
+	flag.Parse()
+
+	return *o.reference == "" || *o.generated == "" || *o.worst < 0
+}
+
+func main() {
+	o := &options{}
+
+	if parseOptions(o) {
+		flag.Usage()
+		return
+	}
+
+	reference, err := readPL2(*o.reference)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	generated, err := readPL2(*o.generated)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := pl2.CompareFidelity(reference, generated, *o.worst)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "section\tmatch\tmean ΔE\tmax ΔE\tworst")
+
+	for _, f := range report.Sections {
+		worst := make([]string, len(f.Worst))
+		for idx, flat := range f.Worst {
+			worst[idx] = f.Section.ElementName(flat)
+		}
+
+		fmt.Fprintf(w, "%s\t%.2f%%\t%.2f\t%.2f\t%s\n",
+			f.Section.Name, f.MatchPercent(), f.MeanDeltaE, f.MaxDeltaE, strings.Join(worst, " "))
+	}
+
+	fmt.Fprintf(w, "total\t%.2f%%\t\t\t\n", report.MatchPercent())
+
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func readPL2(path string) (*pl2.PL2, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read file, %w", err)
+	}
+
+	p, err := pl2.FromBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %s, %w", path, err)
+	}
+
+	return p, nil
+}
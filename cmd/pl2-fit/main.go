@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"log"
+
+	pl2 "github.com/muitdebos/pl2/pkg"
+
+	gpl "github.com/gravestench/gpl/pkg"
+)
+
+type options struct {
+	pl2Path *string
+	gplPath *string
+	out     *string
+	recipe  *string
+}
+
+func parseOptions(o *options) (terminate bool) {
+	o.pl2Path = flag.String("pl2", "", "pl2 file whose generation options are estimated (required)")
+	o.gplPath = flag.String("gpl", "", "palette to generate a new pl2 for, with the estimated options")
+	o.out = flag.String("out", "./Pal.pl2", "the pl2 file generated for the -gpl palette")
+	o.recipe = flag.String("recipe", "", "json recipe file to write the estimated options to, see pl2-from-gpl -recipe")
+
+	flag.Parse()
+
+	return *o.pl2Path == ""
+}
+
+func main() {
+	o := &options{}
+
+	if parseOptions(o) {
+		flag.Usage()
+		return
+	}
+
+	data, err := ioutil.ReadFile(*o.pl2Path)
+	if err != nil {
+		log.Fatal(fmt.Errorf("could not read file, %w", err))
+	}
+
+	src, err := pl2.FromBytes(data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	opts, err := pl2.FitGenerateOptions(src, pl2.DefaultGenerateOptions())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	printOptions(opts)
+
+	regenerated, err := pl2.NewGenerator(opts).Regenerate(src)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	report, err := pl2.CompareFidelity(src, regenerated, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("regenerating with these options matches %.2f%% of the file\n", report.MatchPercent())
+
+	if *o.recipe != "" {
+		if err := writeRecipe(*o.recipe, opts); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *o.gplPath == "" {
+		return
+	}
+
+	if err := generate(*o.gplPath, *o.out, opts); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printOptions(opts pl2.GenerateOptions) {
+	mode := "default"
+	if opts.Mode == pl2.ModeVanilla {
+		mode = "vanilla"
+	}
+
+	metric, _ := pl2.MetricName(opts.Metric)
+
+	fmt.Printf("mode:                     %s\n", mode)
+	fmt.Printf("metric:                   %s\n", metric)
+	fmt.Printf("light level shift:        %d\n", opts.LightLevelShift)
+	fmt.Printf("inverted color shift:     %d\n", opts.InvColorShift)
+	fmt.Printf("selected unit luminosity: %g\n", opts.SelectedUnitLuminosity)
+	fmt.Printf("alpha step:               %g\n", opts.AlphaStep)
+	fmt.Printf("hue step:                 %g\n", opts.HueStep)
+	fmt.Printf("hue saturation:           %g\n", opts.HueSaturation)
+	fmt.Printf("hue darken:               %g\n", opts.HueDarken)
+	fmt.Printf("hue brighten:             %g\n", opts.HueBrighten)
+}
+
+func generate(gplPath, out string, opts pl2.GenerateOptions) error {
+	data, err := ioutil.ReadFile(gplPath)
+	if err != nil {
+		return fmt.Errorf("could not read file, %w", err)
+	}
+
+	gplPalette, err := gpl.Decode(bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("could not decode %s, %w", gplPath, err)
+	}
+
+	generated, err := pl2.NewGenerator(opts).Generate(color.Palette(*gplPalette), nil)
+	if err != nil {
+		return err
+	}
+
+	pl2Bytes, err := pl2.ToBytes(generated)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(out, pl2Bytes, 0644)
+}
+
+func writeRecipe(path string, opts pl2.GenerateOptions) error {
+	r, err := pl2.RecipeFromOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	b := bytes.NewBuffer(nil)
+
+	if err := r.Encode(b); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b.Bytes(), 0644)
+}
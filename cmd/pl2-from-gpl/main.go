@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"text/tabwriter"
 
 	pl2 "github.com/muitdebos/pl2/pkg"
 
@@ -19,14 +20,32 @@ type options struct {
 	gpl       *string
 	out    *string
 	outPrefix *string
+	metric    *string
+	vanilla   *bool
+	blend     *string
+	hue       *string
+	recipe    *string
+	print     *bool
+	diagnose  *float64
 }
 
 func parseOptions(o *options) (terminate bool) {
 	o.gpl = flag.String("gpl", "", "input dcc file (required)")
 	o.out = flag.String("pl2", "./Pal.pl2", "the output directory (required)")
+	o.metric = flag.String("metric", "rgb", fmt.Sprintf("color distance used to pick palette colors, one of %v", pl2.MetricNames()))
+	o.vanilla = flag.Bool("vanilla", false, "generate tables compatible with the ones shipped with the game")
+	o.blend = flag.String("blend", "srgb", "color space of the blend tables, srgb, linear or oklab")
+	o.hue = flag.String("hue", "hsl", "color space of the hue variations, hsl, oklch or hsluv")
+	o.recipe = flag.String("recipe", "", "json recipe describing the generation, -metric, -vanilla, -blend and -hue override it when set")
+	o.print = flag.Bool("print-recipe", false, "print the recipe of the generation and exit, a starting point for -recipe")
+	o.diagnose = flag.Float64("diagnose", 0, "summarize the transforms with entries whose ΔE to their ideal color is above this threshold (optional)")
 
 	flag.Parse()
 
+	if *o.print {
+		return false
+	}
+
 	return *o.gpl == "" || *o.out == ""
 }
 
@@ -38,6 +57,25 @@ func main() {
 		flag.Usage()
 	}
 
+	opts, err := generateOptions(o)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	if *o.print {
+		r, err := pl2.RecipeFromOptions(opts)
+		if err == nil {
+			err = r.Encode(os.Stdout)
+		}
+
+		if err != nil {
+			fmt.Println(err)
+		}
+
+		return
+	}
+
 	data, err := ioutil.ReadFile(*o.gpl)
 	if err != nil {
 		const fmtErr = "could not read file, %v"
@@ -52,7 +90,20 @@ func main() {
 		return
 	}
 
-	pl2Bytes, err := pl2.EncodePalette(color.Palette(*gplPalette))
+	var generated *pl2.PL2
+
+	if *o.diagnose > 0 {
+		generated, err = diagnose(color.Palette(*gplPalette), opts, *o.diagnose)
+	} else {
+		generated, err = pl2.NewGenerator(opts).Generate(color.Palette(*gplPalette), nil)
+	}
+
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	pl2Bytes, err := pl2.ToBytes(generated)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -71,4 +122,115 @@ func main() {
 	if err := f.Close(); err != nil {
 		log.Fatal(err)
 	}
-}
\ No newline at end of file
+}
+
+// diagnose generates the PL2 of the palette, and prints the quantization errors of its sections
+// and the transforms with entries above the ΔE threshold.
+func diagnose(p color.Palette, opts pl2.GenerateOptions, threshold float64) (*pl2.PL2, error) {
+	const maxHotspots = 20
+
+	src := &pl2.PL2{}
+	src.SetMainPalette(p)
+	src.SetTextPalette(nil)
+
+	opts.Sections = nil
+
+	generated, report, err := pl2.NewGenerator(opts).Diagnose(src)
+	if err != nil {
+		return nil, err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "section\tmean ΔE\tmax ΔE")
+
+	for _, s := range report.Sections {
+		fmt.Fprintf(w, "%s\t%.2f\t%.2f\n", s.Section.Name, s.MeanDeltaE, s.MaxDeltaE)
+	}
+
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	hotspots := report.Hotspots(threshold)
+
+	for idx, h := range hotspots {
+		if idx == maxHotspots {
+			fmt.Printf("... and %d more transforms\n", len(hotspots)-maxHotspots)
+			break
+		}
+
+		name := h.Section.ElementName(h.Element)
+		if e, found := pl2.ByElement(h.Section.ID, h.Element); found {
+			name += " (" + e.Name + ")"
+		}
+
+		fmt.Printf("%s has %d entries with ΔE>%g, max %.2f\n", name, h.Count, threshold, h.MaxDeltaE)
+	}
+
+	return generated, nil
+}
+
+// generateOptions returns the options of the recipe, or the default ones, with the options set
+// on the command line.
+func generateOptions(o *options) (pl2.GenerateOptions, error) {
+	opts := pl2.DefaultGenerateOptions()
+
+	if *o.recipe != "" {
+		f, err := os.Open(*o.recipe)
+		if err != nil {
+			return opts, fmt.Errorf("could not read recipe, %w", err)
+		}
+
+		defer func() {
+			_ = f.Close()
+		}()
+
+		r, err := pl2.DecodeRecipe(f)
+		if err != nil {
+			return opts, err
+		}
+
+		if opts, err = r.Options(); err != nil {
+			return opts, fmt.Errorf("invalid recipe %s, %w", *o.recipe, err)
+		}
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	if *o.recipe == "" || set["metric"] {
+		metric, err := pl2.MetricByName(*o.metric)
+		if err != nil {
+			return opts, err
+		}
+
+		opts.Metric = metric
+	}
+
+	if *o.vanilla {
+		opts.Mode = pl2.ModeVanilla
+	}
+
+	if *o.recipe == "" || set["blend"] {
+		switch space := pl2.BlendSpace(*o.blend); space {
+		case pl2.BlendSRGB, pl2.BlendLinear, pl2.BlendOKLab:
+			opts.BlendSpace = space
+		default:
+			return opts, fmt.Errorf("unknown blend space %q", *o.blend)
+		}
+	}
+
+	if *o.recipe == "" || set["hue"] {
+		switch space := pl2.HueSpace(*o.hue); space {
+		case pl2.HueHSL, pl2.HueOKLCH, pl2.HueHSLuv:
+			opts.HueSpace = space
+		default:
+			return opts, fmt.Errorf("unknown hue space %q", *o.hue)
+		}
+	}
+
+	return opts, nil
+}
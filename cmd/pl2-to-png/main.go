@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"image"
 	"image/color"
+	"image/draw"
 	"image/png"
 	"io/ioutil"
 	"os"
@@ -14,13 +15,16 @@ import (
 )
 
 type options struct {
-	pl2     *string
-	pngPath *string
+	pl2        *string
+	pngPath    *string
+	huePreview *string
 }
 
 func parseOptions(o *options) (terminate bool) {
 	o.pl2 = flag.String("pl2", "", "input pl2 file (required)")
 	o.pngPath = flag.String("png", "", "path to output png file (optional)")
+	o.huePreview = flag.String("hue-preview", "", "hue space (oklch, hsluv) to preview, renders "+
+		"the hue variations regenerated in hsl and in this space side by side (optional)")
 
 	flag.Parse()
 
@@ -55,7 +59,17 @@ func main() {
 		pngPath = path.Join(pngPath, "output.png")
 	}
 
-	img := makeImage(pl2)
+	var img image.Image
+
+	if *o.huePreview != "" {
+		img, err = makeHuePreview(pl2, pkg.HueSpace(*o.huePreview))
+		if err != nil {
+			fmt.Print(fmt.Errorf("could not preview hue variations, %w", err))
+			return
+		}
+	} else {
+		img = makeImage(pl2)
+	}
 
 	err = writeImage(pngPath, img)
 	if err != nil {
@@ -84,70 +98,65 @@ func makeImage(pl2 *pkg.PL2) image.Image {
 	return img
 }
 
-func getMainTransforms(p *pkg.PL2) []pkg.Transform {
-	transforms := make([]pkg.Transform, 0)
+// makeHuePreview renders the hue variations regenerated from the pl2 palette in HSL, on the left,
+// and in the given hue space, on the right.
+func makeHuePreview(pl2 *pkg.PL2, space pkg.HueSpace) (image.Image, error) {
+	const (
+		width = 256
+	)
 
-	baseTransform := pkg.Transform{}
-	for idx := range baseTransform {
-		baseTransform[idx] = uint8(idx)
+	if space != pkg.HueOKLCH && space != pkg.HueHSLuv {
+		return nil, fmt.Errorf("unknown hue space %q, expected %q or %q", space, pkg.HueOKLCH, pkg.HueHSLuv)
 	}
 
-	addTransform := func(t pkg.Transform) {
-		transforms = append(transforms, t)
-	}
+	var img *image.RGBA
 
-	addTransforms := func(transforms []pkg.Transform) {
-		for idx := range transforms {
-			addTransform(transforms[idx])
-		}
-	}
+	for idx, hueSpace := range []pkg.HueSpace{pkg.HueHSL, space} {
+		opts := pkg.DefaultGenerateOptions()
+		opts.HueSpace = hueSpace
+		opts.Sections = []pkg.SectionID{pkg.SectionHueVariations}
 
-	addTransform(baseTransform)
-	addTransforms(p.LightLevelVariations)
-	addTransforms(p.InvColorVariations)
+		regenerated, err := pkg.NewGenerator(opts).Regenerate(pl2)
+		if err != nil {
+			return nil, err
+		}
 
-	addTransform(p.SelectedUnitShift)
+		transforms := getTransforms(regenerated, pkg.SectionHueVariations, pkg.SectionHueVariations)
 
-	for idx := range p.AlphaBlend {
-		addTransforms(p.AlphaBlend[idx])
-	}
+		if img == nil {
+			img = image.NewRGBA(image.Rect(0, 0, 2*width, len(transforms)))
+		}
 
-	addTransforms(p.AdditiveBlend)
-	addTransforms(p.MultiplicativeBlend)
-	addTransforms(p.HueVariations)
+		half := image.NewRGBA(image.Rect(0, 0, width, len(transforms)))
 
-	addTransform(p.RedTones)
-	addTransform(p.GreenTones)
-	addTransform(p.BlueTones)
+		for row := range transforms {
+			writeTransformAsRowInImage(row, &transforms[row], pl2.BasePalette, half)
+		}
 
-	addTransforms(p.UnknownVariations)
+		draw.Draw(img, half.Bounds().Add(image.Pt(idx*width, 0)), half, image.Point{}, draw.Src)
+	}
 
-	addTransforms(p.MaxComponentBlend)
-	addTransform(p.DarkenedColorShift)
+	return img, nil
+}
 
-	return transforms
+func getMainTransforms(p *pkg.PL2) []pkg.Transform {
+	return getTransforms(p, pkg.SectionLightLevelVariations, pkg.SectionDarkenedColorShift)
 }
 
 func getTextTransforms(p *pkg.PL2) []pkg.Transform {
-	transforms := make([]pkg.Transform, 0)
-
-	baseTransform := pkg.Transform{}
-	for idx := range baseTransform {
-		baseTransform[idx] = uint8(idx)
-	}
+	return getTransforms(p, pkg.SectionTextColorShifts, pkg.SectionTextColorShifts)
+}
 
-	addTransform := func(t pkg.Transform) {
-		transforms = append(transforms, t)
-	}
+// getTransforms returns an identity transform, followed by the transforms of the sections
+// between first and last, in file order.
+func getTransforms(p *pkg.PL2, first, last pkg.SectionID) []pkg.Transform {
+	transforms := []pkg.Transform{pkg.Identity()}
 
-	addTransforms := func(transforms []pkg.Transform) {
-		for idx := range transforms {
-			addTransform(transforms[idx])
+	p.Each(func(id pkg.TransformID, t *pkg.Transform) {
+		if id.Section >= first && id.Section <= last {
+			transforms = append(transforms, *t)
 		}
-	}
-
-	addTransform(baseTransform)
-	addTransforms(p.TextColorShifts)
+	})
 
 	return transforms
 }
@@ -3,7 +3,6 @@ module github.com/muitdebos/pl2
 go 1.16
 
 require (
-	github.com/OpenDiablo2/bitstream v0.0.0-20210818234514-9fca7e40e2b3
 	github.com/gravestench/gpl v0.0.0-20210615232229-779e263cf91e
 	github.com/lucasb-eyer/go-colorful v1.2.0
 )
@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"image/color"
+	"math"
+
+	color2 "github.com/lucasb-eyer/go-colorful"
+)
+
+// BlendSpace is the color space in which the blend tables blend colors.
+type BlendSpace string
+
+// Blend spaces
+const (
+	// BlendSRGB blends 8 bit sRGB components, the historical behavior. Translucent colors over
+	// dark colors come out too dark.
+	BlendSRGB BlendSpace = "srgb"
+	// BlendLinear blends linear light components, like modern engines do.
+	BlendLinear BlendSpace = "linear"
+	// BlendOKLab interpolates colors in OKLab for the alpha and max component blends, which
+	// keeps their perceived lightness and hue. Additive and multiplicative blends are done in
+	// linear light.
+	BlendOKLab BlendSpace = "oklab"
+)
+
+// blendOp blends a source color over a destination color.
+type blendOp struct {
+	// srgb blends 8 bit sRGB components
+	srgb blendFn
+	// linear blends linear light components in [0, 1]
+	linear func(s, d float64) float64
+	// weight, when set, makes the blend an interpolation which gives the returned weight to the
+	// source color, in every blend space
+	weight func(src, dst int) float64
+}
+
+// blendSpaceColors holds a base palette color in the blend spaces.
+type blendSpaceColors struct {
+	linear [3]float64
+	oklab  [3]float64
+}
+
+// getBlendSpaceColors returns the base palette colors converted once for the whole generation,
+// it is safe to call from several row generators at once.
+func (g *generation) getBlendSpaceColors() []blendSpaceColors {
+	g.blendColorsOnce.Do(func() {
+		colors := make([]blendSpaceColors, numPaletteColors)
+
+		for idx := range colors {
+			c := toColorful(g.pl2.BasePalette[idx])
+
+			r, gr, b := c.LinearRgb()
+			colors[idx].linear = [3]float64{r, gr, b}
+
+			l, a, bb := oklab(c)
+			colors[idx].oklab = [3]float64{l, a, bb}
+		}
+
+		g.blendColorsBuffer = colors
+	})
+
+	return g.blendColorsBuffer
+}
+
+// blendsInSpace tells whether colors are blended in the linear or OKLab blend space, instead of
+// blending sRGB components.
+func (g *generation) blendsInSpace() bool {
+	return g.opts.BlendSpace == BlendLinear || g.opts.BlendSpace == BlendOKLab
+}
+
+// blendInSpace blends two base palette colors in the linear or OKLab blend space.
+func (g *generation) blendInSpace(src, dst int, op blendOp) color.Color {
+	colors := g.getBlendSpaceColors()
+	s, d := colors[src], colors[dst]
+
+	if op.weight != nil {
+		w := op.weight(src, dst)
+
+		if g.opts.BlendSpace == BlendOKLab {
+			l, a, b := lerp3(s.oklab, d.oklab, w)
+
+			return fromOklab(l, a, b).Clamped()
+		}
+
+		r, gr, b := lerp3(s.linear, d.linear, w)
+
+		return clampedLinearRgb(r, gr, b)
+	}
+
+	return clampedLinearRgb(
+		op.linear(s.linear[0], d.linear[0]),
+		op.linear(s.linear[1], d.linear[1]),
+		op.linear(s.linear[2], d.linear[2]),
+	)
+}
+
+// lerp3 interpolates from d toward s, giving the weight w to s.
+func lerp3(s, d [3]float64, w float64) (x, y, z float64) {
+	return s[0]*w + d[0]*(1-w), s[1]*w + d[1]*(1-w), s[2]*w + d[2]*(1-w)
+}
+
+func clampedLinearRgb(r, g, b float64) color.Color {
+	clamp := func(v float64) float64 {
+		return math.Min(1, math.Max(0, v))
+	}
+
+	return color2.LinearRgb(clamp(r), clamp(g), clamp(b))
+}
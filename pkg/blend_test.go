@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"testing"
+)
+
+func TestGenerator_blendSpaces(t *testing.T) {
+	const white, nearBlack, gray = 255, 1, 128
+
+	// with the default grayscale palette, palette indices are gray levels
+	tests := []struct {
+		space    BlendSpace
+		section  SectionID
+		flat     int
+		src, dst int
+		want     int
+	}{
+		{BlendSRGB, SectionAlphaBlend, alphaBlendFine + white, white, nearBlack, 127},
+		{BlendLinear, SectionAlphaBlend, alphaBlendFine + white, white, nearBlack, 188},
+		{BlendOKLab, SectionAlphaBlend, alphaBlendFine + white, white, nearBlack, 109},
+		{BlendSRGB, SectionAdditiveBlend, gray, gray, gray, 255},
+		{BlendLinear, SectionAdditiveBlend, gray, gray, gray, 176},
+		{BlendSRGB, SectionMultiplicativeBlend, gray, gray, gray, 64},
+		{BlendLinear, SectionMultiplicativeBlend, gray, gray, gray, 61},
+		{BlendSRGB, SectionMaxComponentBlend, white, white, gray, 191},
+		{BlendOKLab, SectionMaxComponentBlend, white, white, gray, 189},
+		{BlendLinear, SectionMaxComponentBlend, white, white, gray, 204},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.space)+" "+tt.section.String(), func(t *testing.T) {
+			opts := VanillaGenerateOptions()
+			opts.BlendSpace = tt.space
+			opts.Sections = []SectionID{tt.section}
+
+			src := &PL2{}
+			src.allocateTransforms()
+
+			pl2, err := NewGenerator(opts).Regenerate(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := int(pl2.Transforms(tt.section)[tt.flat][tt.dst])
+
+			if diff := got - tt.want; diff < -1 || diff > 1 {
+				t.Errorf("%s over %d is %d, want %d", tt.section.Section().ElementName(tt.flat), tt.dst, got, tt.want)
+			}
+		})
+	}
+}
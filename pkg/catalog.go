@@ -0,0 +1,210 @@
+package pkg
+
+import (
+	"fmt"
+)
+
+// CatalogEntry names a transform of a PL2. Names are stable, lowercase and unique, eg.
+// "hue-shift-75-dark" for HueVariations[29]. Hue variations are described as laid out by
+// DefaultHueGroups, which files generated with custom hue groups may not follow.
+type CatalogEntry struct {
+	Section SectionID
+	// Element is the flat index of the transform in its section.
+	Element     int
+	Name        string
+	Description string
+	// Code is the colors.txt code of the color the transform produces, eg. "cblu", empty when
+	// the transform does not produce one of the colors of colors.txt.
+	Code string
+}
+
+// ID returns the identifier of the transform, to address it with PL2.Get and PL2.Set.
+func (e CatalogEntry) ID() TransformID {
+	return TransformID{Section: e.Section, Element: e.Element}
+}
+
+// ElementName returns the element name of the transform, eg. "HueVariations[29]".
+func (e CatalogEntry) ElementName() string {
+	return e.ID().String()
+}
+
+// hueNames names the hues of the saturated hue variations, 30 degrees apart.
+var hueNames = []string{
+	"red", "orange", "yellow", "chartreuse", "green", "spring-green",
+	"cyan", "azure", "blue", "violet", "magenta", "rose",
+}
+
+// catalogCodes are the colors.txt codes of the transforms producing their colors, by entry
+// name. Hue shifts rotate hues, and keep the colors of the other codes out of reach.
+var catalogCodes = map[string]string{
+	"grayscale-bright":  "lgry",
+	"grayscale-dark":    "dgry",
+	"black":             "blac",
+	"saturated-blue":    "cblu",
+	"saturated-red":     "cred",
+	"saturated-green":   "cgrn",
+	"saturated-orange":  "oran",
+	"inverted-color-15": "bwht",
+}
+
+var textColorNames = []string{
+	"white", "red", "green", "blue", "gold", "gray", "black",
+	"tan", "orange", "yellow", "dark-green", "purple", "bright-green",
+}
+
+var catalog, catalogByName, catalogByCode, catalogByElement = buildCatalog()
+
+func buildCatalog() ([]CatalogEntry, map[string]int, map[string]int, map[TransformID]int) {
+	entries := make([]CatalogEntry, 0, NumTransforms)
+
+	add := func(id SectionID, element int, name, description string) {
+		entries = append(entries, CatalogEntry{
+			Section:     id,
+			Element:     element,
+			Name:        name,
+			Description: description,
+			Code:        catalogCodes[name],
+		})
+	}
+
+	for idx := 0; idx < lightLevelVariations; idx++ {
+		add(SectionLightLevelVariations, idx, fmt.Sprintf("light-level-%d", idx),
+			fmt.Sprintf("Light level %d of %d, the darkest first", idx+1, lightLevelVariations))
+	}
+
+	for idx := 0; idx < invColorVariations; idx++ {
+		add(SectionInvColorVariations, idx, fmt.Sprintf("inverted-color-%d", idx),
+			fmt.Sprintf("Colors faded toward white, level %d of %d", idx+1, invColorVariations))
+	}
+
+	add(SectionSelectedUnitShift, 0, "selected-unit", "Brightened colors of the unit under the cursor")
+
+	for level := 0; level < alphaBlendCoarse; level++ {
+		opacity := (level + 1) * 100 / (alphaBlendCoarse + 1)
+
+		for src := 0; src < alphaBlendFine; src++ {
+			add(SectionAlphaBlend, level*alphaBlendFine+src, fmt.Sprintf("alpha-%d-%d", opacity, src),
+				fmt.Sprintf("Palette color %d drawn with a %d%% opacity over each color", src, opacity))
+		}
+	}
+
+	for src := 0; src < additiveBlends; src++ {
+		add(SectionAdditiveBlend, src, fmt.Sprintf("additive-%d", src),
+			fmt.Sprintf("Palette color %d added to each color", src))
+	}
+
+	for src := 0; src < multiplyBlends; src++ {
+		add(SectionMultiplicativeBlend, src, fmt.Sprintf("multiplicative-%d", src),
+			fmt.Sprintf("Each color multiplied by palette color %d", src))
+	}
+
+	addHueVariations(add)
+
+	add(SectionRedTones, 0, "red-tones", "Colors as tones of red")
+	add(SectionGreenTones, 0, "green-tones", "Colors as tones of green")
+	add(SectionBlueTones, 0, "blue-tones", "Colors as tones of blue")
+
+	for idx := 0; idx < unknownVariations; idx++ {
+		add(SectionUnknownVariations, idx, fmt.Sprintf("unknown-%d", idx),
+			fmt.Sprintf("Unknown variation %d, see GenerateOptions.UnknownVariations", idx+1))
+	}
+
+	for src := 0; src < maxComponentBlends; src++ {
+		add(SectionMaxComponentBlend, src, fmt.Sprintf("max-component-%d", src),
+			fmt.Sprintf("Palette color %d drawn over each color, less so over bright colors", src))
+	}
+
+	add(SectionDarkenedColorShift, 0, "darkened", "Colors darkened to a third")
+
+	for idx := 0; idx < textShifts; idx++ {
+		add(SectionTextColorShifts, idx, "text-"+textColorNames[idx],
+			fmt.Sprintf("Colors scaled by text color %d, %s by default", idx, textColorNames[idx]))
+	}
+
+	byName, byCode, byElement := make(map[string]int), make(map[string]int), make(map[TransformID]int)
+
+	for idx, e := range entries {
+		byName[e.Name] = idx
+		byElement[e.ID()] = idx
+
+		if e.Code != "" {
+			byCode[e.Code] = idx
+		}
+	}
+
+	return entries, byName, byCode, byElement
+}
+
+// addHueVariations adds the hue variations, as laid out by DefaultHueGroups.
+func addHueVariations(add func(id SectionID, element int, name, description string)) {
+	step, element := int(maxDegrees)/hueSteps, 0
+	next := func(name, description string) {
+		add(SectionHueVariations, element, name, description)
+		element++
+	}
+
+	shifts := []struct{ suffix, description string }{
+		{"", ""},
+		{"-dark", ", half saturated and darkened"},
+		{"-bright", ", half saturated and brightened"},
+	}
+
+	for _, shift := range shifts {
+		for idx := 0; idx < hueSteps; idx++ {
+			degrees := idx * step
+			next(fmt.Sprintf("hue-shift-%d%s", degrees, shift.suffix),
+				fmt.Sprintf("Hues rotated by %d degrees%s", degrees, shift.description))
+		}
+	}
+
+	next("grayscale-dark", "Grayscale at half lightness, used by revived monsters")
+	next("grayscale-bright", "Brightened grayscale")
+
+	for idx := 0; idx < hueSteps; idx++ {
+		degrees := idx * step
+		next(fmt.Sprintf("hue-shift-%d-except-red", degrees),
+			fmt.Sprintf("Hues rotated by %d degrees, except for colors close to red", degrees))
+	}
+
+	next("black", "Full black, only generated in vanilla mode")
+
+	for _, hue := range hueNames {
+		next("saturated-"+hue, fmt.Sprintf("Colors at full saturation with a %s hue", hue))
+	}
+}
+
+// Catalog returns the entries naming every transform of a PL2, in file order.
+func Catalog() []CatalogEntry {
+	return append([]CatalogEntry(nil), catalog...)
+}
+
+// ByName returns the catalog entry with the given name.
+func ByName(name string) (CatalogEntry, bool) {
+	idx, found := catalogByName[name]
+	if !found {
+		return CatalogEntry{}, false
+	}
+
+	return catalog[idx], true
+}
+
+// ByElement returns the catalog entry of the transform at the flat index of the section.
+func ByElement(id SectionID, element int) (CatalogEntry, bool) {
+	idx, found := catalogByElement[TransformID{Section: id, Element: element}]
+	if !found {
+		return CatalogEntry{}, false
+	}
+
+	return catalog[idx], true
+}
+
+// ByCode returns the catalog entry of the transform producing the color with the given
+// colors.txt code, eg. "cblu".
+func ByCode(code string) (CatalogEntry, bool) {
+	idx, found := catalogByCode[code]
+	if !found {
+		return CatalogEntry{}, false
+	}
+
+	return catalog[idx], true
+}
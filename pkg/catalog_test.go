@@ -0,0 +1,171 @@
+package pkg
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	color2 "github.com/lucasb-eyer/go-colorful"
+)
+
+func TestCatalog(t *testing.T) {
+	entries := Catalog()
+	if len(entries) != NumTransforms {
+		t.Fatalf("catalog has %d entries, want %d", len(entries), NumTransforms)
+	}
+
+	names := make(map[string]bool)
+	idx := 0
+
+	// entries follow the file order, one per transform
+	for _, section := range Layout() {
+		if !section.ID.IsTransforms() {
+			continue
+		}
+
+		for flat := 0; flat < section.Count; flat++ {
+			e := entries[idx]
+			idx++
+
+			if e.Section != section.ID || e.Element != flat {
+				t.Fatalf("entry %s is %s, want %s", e.Name, e.ElementName(), section.ElementName(flat))
+			}
+
+			if names[e.Name] {
+				t.Fatalf("name %s is not unique", e.Name)
+			}
+
+			names[e.Name] = true
+		}
+	}
+}
+
+// huePalette returns a palette with black at index 0, saturated colors of 24 hues 15 degrees
+// apart at 7 lightnesses, and a ramp of grays up to white.
+func huePalette() color.Palette {
+	p := color.Palette{color.RGBA{A: 255}}
+
+	for h := 0.0; h < 360; h += 15 {
+		for l := 0.2; l < 0.85; l += 0.1 {
+			r, g, b := color2.Hsl(h, 1, l).RGB255()
+			p = append(p, color.RGBA{R: r, G: g, B: b, A: 255})
+		}
+	}
+
+	grays := numPaletteColors - len(p)
+
+	for gray := 0; gray < grays; gray++ {
+		v := uint8(gray * math.MaxUint8 / (grays - 1))
+		p = append(p, color.RGBA{R: v, G: v, B: v, A: 255})
+	}
+
+	return p
+}
+
+// TestCatalog_codes checks that the transforms with a colors.txt code produce its color.
+func TestCatalog_codes(t *testing.T) {
+	p := Generate(huePalette(), VanillaGenerateOptions())
+
+	hues := map[string]float64{"cred": 0, "oran": 30, "cgrn": 120, "cblu": 240}
+	lightness := make(map[string]float64)
+
+	for _, e := range Catalog() {
+		if e.Code == "" {
+			continue
+		}
+
+		tr, err := p.Get(e.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the circular mean of the hues of the chromatic colors
+		chromatic, x, y := 0, 0.0, 0.0
+
+		for entry := 1; entry < numPaletteColors; entry++ {
+			h, s, l := rgba2hsl(p.BasePalette[tr[entry]]).Hsl()
+			lightness[e.Code] += l / (numPaletteColors - 1)
+
+			switch e.Code {
+			case "lgry", "dgry":
+				if s > 0.05 {
+					t.Fatalf("%s maps %d to a saturation of %.2f, want gray", e.Name, entry, s)
+				}
+			case "blac":
+				if l > 0.05 {
+					t.Fatalf("%s maps %d to a lightness of %.2f, want black", e.Name, entry, l)
+				}
+			case "bwht":
+				if l < 0.95 {
+					t.Fatalf("%s maps %d to a lightness of %.2f, want white", e.Name, entry, l)
+				}
+			default:
+				if _, found := hues[e.Code]; !found {
+					t.Fatalf("code %s of %s is not checked", e.Code, e.Name)
+				}
+
+				if s > 0.25 && l > 0.1 && l < 0.9 {
+					chromatic++
+					x, y = x+math.Cos(h*math.Pi/180), y+math.Sin(h*math.Pi/180)
+				}
+			}
+		}
+
+		want, found := hues[e.Code]
+		if !found {
+			continue
+		}
+
+		if chromatic < numPaletteColors/2 {
+			t.Errorf("%s maps only %d colors to a hue", e.Name, chromatic)
+		}
+
+		h := math.Atan2(y, x) * 180 / math.Pi
+		if d := math.Abs(math.Mod(h-want+540, 360) - 180); d > 10 {
+			t.Errorf("%s maps colors to a mean hue of %.0f, want %.0f", e.Name, h, want)
+		}
+	}
+
+	if lightness["dgry"] >= lightness["lgry"] {
+		t.Errorf("dgry has a mean lightness of %.2f, lgry %.2f", lightness["dgry"], lightness["lgry"])
+	}
+}
+
+func TestCatalog_lookups(t *testing.T) {
+	tests := []struct {
+		name, code, element string
+	}{
+		{"hue-shift-75-dark", "", "HueVariations[29]"},
+		{"hue-shift-240-dark", "", "HueVariations[40]"},
+		{"grayscale-dark", "dgry", "HueVariations[72]"},
+		{"black", "blac", "HueVariations[98]"},
+		{"saturated-blue", "cblu", "HueVariations[107]"},
+		{"alpha-75-37", "", "AlphaBlend[2][37]"},
+		{"text-gold", "", "TextColorShifts[4]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, found := ByName(tt.name)
+			if !found || e.ElementName() != tt.element {
+				t.Fatalf("ByName(%q) = %s, %v, want %s", tt.name, e.ElementName(), found, tt.element)
+			}
+
+			if got, found := ByElement(e.Section, e.Element); !found || got != e {
+				t.Errorf("ByElement(%s) = %s, %v", tt.element, got.Name, found)
+			}
+
+			if tt.code == "" {
+				return
+			}
+
+			if e, found := ByCode(tt.code); !found || e.Name != tt.name {
+				t.Errorf("ByCode(%q) = %s, %v, want %s", tt.code, e.Name, found, tt.name)
+			}
+		})
+	}
+
+	if _, found := ByName("hue-shift-7"); found {
+		t.Error("unexpected entry")
+	}
+}
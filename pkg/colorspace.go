@@ -0,0 +1,109 @@
+package pkg
+
+import (
+	"image/color"
+	"math"
+
+	color2 "github.com/lucasb-eyer/go-colorful"
+)
+
+// toColorful converts a color to a go-colorful color, ignoring its alpha. Unlike
+// go-colorful's MakeColor, colors with a zero alpha keep their RGB components.
+func toColorful(c color.Color) color2.Color {
+	const maxComponent = 0xFFFF
+
+	r, g, b, _ := c.RGBA()
+
+	return color2.Color{
+		R: float64(r) / maxComponent,
+		G: float64(g) / maxComponent,
+		B: float64(b) / maxComponent,
+	}
+}
+
+// oklab returns the OKLab coordinates of a color, see https://bottosson.github.io/posts/oklab/
+func oklab(c color2.Color) (l, a, b float64) {
+	lr, lg, lb := c.LinearRgb()
+
+	lms0 := math.Cbrt(0.4122214708*lr + 0.5363325363*lg + 0.0514459929*lb)
+	lms1 := math.Cbrt(0.2119034982*lr + 0.6806995451*lg + 0.1073969566*lb)
+	lms2 := math.Cbrt(0.0883024619*lr + 0.2817188376*lg + 0.6299787005*lb)
+
+	l = 0.2104542553*lms0 + 0.7936177850*lms1 - 0.0040720468*lms2
+	a = 1.9779984951*lms0 - 2.4285922050*lms1 + 0.4505937099*lms2
+	b = 0.0259040371*lms0 + 0.7827717662*lms1 - 0.8086757660*lms2
+
+	return l, a, b
+}
+
+// fromOklab returns the color with the given OKLab coordinates. The result may lie outside of
+// the sRGB gamut, use Clamped to bring it back.
+func fromOklab(l, a, b float64) color2.Color {
+	lms0 := cube(l + 0.3963377774*a + 0.2158037573*b)
+	lms1 := cube(l - 0.1055613458*a - 0.0638541728*b)
+	lms2 := cube(l - 0.0894841775*a - 1.2914855480*b)
+
+	return color2.LinearRgb(
+		+4.0767416621*lms0-3.3077115913*lms1+0.2309699292*lms2,
+		-1.2684380046*lms0+2.6097574011*lms1-0.3413193965*lms2,
+		-0.0041960863*lms0-0.7034186147*lms1+1.7076147010*lms2,
+	)
+}
+
+func cube(v float64) float64 {
+	return v * v * v
+}
+
+// HueSpace is the cylindrical color space in which hue variations are generated.
+type HueSpace string
+
+// Hue spaces
+const (
+	// HueHSL rotates hues in HSL, the historical behavior. Lightness jumps between hues, blue
+	// variations look much darker than yellow ones.
+	HueHSL HueSpace = "hsl"
+	// HueOKLCH rotates hues in OKLCH, the cylindrical form of OKLab, which preserves perceived
+	// lightness. Saturations are chroma relative to oklchMaxChroma.
+	HueOKLCH HueSpace = "oklch"
+	// HueHSLuv rotates hues in HSLuv, a perceptually uniform variant of HSL.
+	HueHSLuv HueSpace = "hsluv"
+)
+
+// oklchMaxChroma is the OKLCH chroma of a full saturation, about the chroma of the most
+// saturated sRGB colors
+const oklchMaxChroma = 0.32
+
+// hueCoordinates returns the hue in degrees, and the saturation and lightness in [0, 1], of a
+// color in the given hue space.
+func hueCoordinates(space HueSpace, c color2.Color) (h, s, l float64) {
+	switch space {
+	case HueOKLCH:
+		l, a, b := oklab(c)
+
+		h = math.Atan2(b, a) * 180 / math.Pi
+		if h < 0 {
+			h += 360
+		}
+
+		return h, math.Hypot(a, b) / oklchMaxChroma, l
+	case HueHSLuv:
+		return c.HSLuv()
+	}
+
+	return c.Hsl()
+}
+
+// fromHueCoordinates returns the sRGB color with the given coordinates in the hue space, see
+// hueCoordinates. Colors out of the sRGB gamut are clamped.
+func fromHueCoordinates(space HueSpace, h, s, l float64) color2.Color {
+	switch space {
+	case HueOKLCH:
+		chroma, rad := s*oklchMaxChroma, h*math.Pi/180
+
+		return fromOklab(l, chroma*math.Cos(rad), chroma*math.Sin(rad)).Clamped()
+	case HueHSLuv:
+		return color2.HSLuv(h, s, l).Clamped()
+	}
+
+	return color2.Hsl(h, s, l)
+}
@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGenerator_hueSpaces(t *testing.T) {
+	p := webSafePalette()
+
+	// lightness spread of the shifted variations of a saturated blue
+	spread := func(space HueSpace) float64 {
+		opts := DefaultGenerateOptions()
+		opts.HueSpace = space
+		opts.Sections = []SectionID{SectionHueVariations}
+
+		src := &PL2{BasePalette: p}
+		src.allocateTransforms()
+
+		pl2, err := NewGenerator(opts).Regenerate(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		const blue = 6
+		lo, hi := math.Inf(1), math.Inf(-1)
+
+		for _, t := range pl2.HueVariations[:DefaultHueGroups(opts)[0].Count] {
+			l, _, _ := oklab(toColorful(p[t[blue]]))
+			lo, hi = math.Min(lo, l), math.Max(hi, l)
+		}
+
+		return hi - lo
+	}
+
+	hsl := spread(HueHSL)
+
+	for _, space := range []HueSpace{HueOKLCH, HueHSLuv} {
+		if got := spread(space); got >= hsl/2 {
+			t.Errorf("%s lightness spread is %.3f, want less than half of HSL's %.3f", space, got, hsl)
+		}
+	}
+}
+
+func TestHueCoordinates(t *testing.T) {
+	p := webSafePalette()
+
+	for _, space := range []HueSpace{HueHSL, HueOKLCH, HueHSLuv} {
+		for _, c := range p {
+			h, s, l := hueCoordinates(space, toColorful(c))
+
+			if got := p[p.Index(fromHueCoordinates(space, h, s, l))]; got != c {
+				t.Fatalf("%s round trip of %v gives %v", space, c, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package pkg
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// BlendMode selects the blend table compositing a source image over a destination image.
+type BlendMode int
+
+// Blend modes
+const (
+	// BlendAlpha25 draws the source with a 25% opacity, using AlphaBlend[0].
+	BlendAlpha25 BlendMode = iota
+	// BlendAlpha50 draws the source with a 50% opacity, using AlphaBlend[1].
+	BlendAlpha50
+	// BlendAlpha75 draws the source with a 75% opacity, using AlphaBlend[2].
+	BlendAlpha75
+	// BlendAdditive adds the source to the destination, using AdditiveBlend.
+	BlendAdditive
+	// BlendMultiplicative multiplies the destination by the source, using MultiplicativeBlend.
+	BlendMultiplicative
+	// BlendMaxComponent keeps more of the destination the brighter its brightest component is,
+	// using MaxComponentBlend.
+	BlendMaxComponent
+)
+
+var blendModeNames = map[BlendMode]string{
+	BlendAlpha25:        "alpha25",
+	BlendAlpha50:        "alpha50",
+	BlendAlpha75:        "alpha75",
+	BlendAdditive:       "additive",
+	BlendMultiplicative: "multiplicative",
+	BlendMaxComponent:   "max",
+}
+
+func (m BlendMode) String() string {
+	if name, found := blendModeNames[m]; found {
+		return name
+	}
+
+	return fmt.Sprintf("BlendMode(%d)", int(m))
+}
+
+// ParseBlendMode returns the blend mode with the given name, eg. "alpha50" or "additive".
+func ParseBlendMode(name string) (BlendMode, error) {
+	for mode := BlendAlpha25; mode <= BlendMaxComponent; mode++ {
+		if blendModeNames[mode] == name {
+			return mode, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown blend mode %q", name)
+}
+
+// table returns the blend table of the mode, indexed by source then destination index.
+func (m BlendMode) table(pl2 *PL2) ([]Transform, error) {
+	var table []Transform
+
+	switch m {
+	case BlendAlpha25, BlendAlpha50, BlendAlpha75:
+		if level := int(m - BlendAlpha25); level < len(pl2.AlphaBlend) {
+			table = pl2.AlphaBlend[level]
+		}
+	case BlendAdditive:
+		table = pl2.AdditiveBlend
+	case BlendMultiplicative:
+		table = pl2.MultiplicativeBlend
+	case BlendMaxComponent:
+		table = pl2.MaxComponentBlend
+	default:
+		return nil, fmt.Errorf("unknown blend mode %v", m)
+	}
+
+	if len(table) != numPaletteColors {
+		const fmtErr = "could not composite, %v blend table has %d transforms, expected %d"
+		return nil, fmt.Errorf(fmtErr, m, len(table), numPaletteColors)
+	}
+
+	return table, nil
+}
+
+// Composite draws the source image over the destination image by looking up the blend table of
+// the mode, as the game renderer does. Both images hold indices into the base palette, and
+// share the same coordinate space. The result has the bounds of the destination and the base
+// palette. Source pixels with index 0 are transparent and leave the destination untouched.
+func Composite(src, dst *image.Paletted, pl2 *PL2, mode BlendMode) (*image.Paletted, error) {
+	table, err := mode.table(pl2)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkBasePalette(pl2); err != nil {
+		return nil, err
+	}
+
+	out := image.NewPaletted(dst.Rect, pl2.BasePalette)
+
+	for y := dst.Rect.Min.Y; y < dst.Rect.Max.Y; y++ {
+		for x := dst.Rect.Min.X; x < dst.Rect.Max.X; x++ {
+			out.SetColorIndex(x, y, dst.ColorIndexAt(x, y))
+		}
+	}
+
+	composite(src, dst, func(x, y int, s, d uint8) {
+		out.SetColorIndex(x, y, table[s][d])
+	})
+
+	return out, nil
+}
+
+// CompositeReference renders the blend of Composite in true color, blending the base palette
+// colors of the source and destination pixels instead of looking up the blend table. Comparing
+// both renders shows what the blend tables lose.
+func CompositeReference(src, dst *image.Paletted, pl2 *PL2, mode BlendMode) (*image.RGBA, error) {
+	if mode < BlendAlpha25 || mode > BlendMaxComponent {
+		return nil, fmt.Errorf("unknown blend mode %v", mode)
+	}
+
+	if err := checkBasePalette(pl2); err != nil {
+		return nil, err
+	}
+
+	out := image.NewRGBA(dst.Rect)
+
+	for y := dst.Rect.Min.Y; y < dst.Rect.Max.Y; y++ {
+		for x := dst.Rect.Min.X; x < dst.Rect.Max.X; x++ {
+			out.Set(x, y, pl2.BasePalette[dst.ColorIndexAt(x, y)])
+		}
+	}
+
+	composite(src, dst, func(x, y int, s, d uint8) {
+		out.SetRGBA(x, y, blendReference(pl2.BasePalette[s], pl2.BasePalette[d], mode))
+	})
+
+	return out, nil
+}
+
+func checkBasePalette(pl2 *PL2) error {
+	if len(pl2.BasePalette) != numPaletteColors {
+		const fmtErr = "could not composite, base palette has %d colors, expected %d"
+		return fmt.Errorf(fmtErr, len(pl2.BasePalette), numPaletteColors)
+	}
+
+	return nil
+}
+
+// composite calls blend with the indices of the visible source pixels over the destination.
+func composite(src, dst *image.Paletted, blend func(x, y int, s, d uint8)) {
+	r := src.Rect.Intersect(dst.Rect)
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			s := src.ColorIndexAt(x, y)
+			if s == 0 {
+				continue
+			}
+
+			blend(x, y, s, dst.ColorIndexAt(x, y))
+		}
+	}
+}
+
+// blendReference blends the 8 bit components of two colors like the blend tables do, without
+// looking up the closest palette color.
+func blendReference(src, dst color.Color, mode BlendMode) color.RGBA {
+	sr, sg, sb, _ := src.RGBA()
+	dr, dg, db, _ := dst.RGBA()
+
+	s := [3]float64{float64(sr >> 8), float64(sg >> 8), float64(sb >> 8)}
+	d := [3]float64{float64(dr >> 8), float64(dg >> 8), float64(db >> 8)}
+	out := [3]float64{}
+
+	// the weight of the destination in the max component blend
+	maxWeight := math.Max(d[0], math.Max(d[1], d[2])) / math.MaxUint8
+
+	for c := range out {
+		switch mode {
+		case BlendAlpha25, BlendAlpha50, BlendAlpha75:
+			alpha := float64(mode-BlendAlpha25+1) / 4
+			out[c] = s[c]*alpha + d[c]*(1-alpha)
+		case BlendAdditive:
+			out[c] = s[c] + d[c]
+		case BlendMultiplicative:
+			out[c] = s[c] * d[c] / math.MaxUint8
+		case BlendMaxComponent:
+			out[c] = s[c]*(1-maxWeight) + d[c]*maxWeight
+		}
+	}
+
+	return color.RGBA{R: round8(out[0]), G: round8(out[1]), B: round8(out[2]), A: math.MaxUint8}
+}
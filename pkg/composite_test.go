@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func TestComposite(t *testing.T) {
+	p := Generate(webSafePalette(), VanillaGenerateOptions())
+	rng := rand.New(rand.NewSource(3))
+
+	randomImage := func(r image.Rectangle) *image.Paletted {
+		img := image.NewPaletted(r, p.BasePalette)
+		for idx := range img.Pix {
+			img.Pix[idx] = uint8(1 + rng.Intn(numPaletteColors-1))
+		}
+
+		return img
+	}
+
+	src, dst := randomImage(image.Rect(8, 8, 40, 40)), randomImage(image.Rect(0, 0, 32, 32))
+	src.SetColorIndex(10, 10, 0)
+
+	// the blend tables read the other way around, the destination index first
+	transposed := p.Clone()
+	for _, section := range []SectionID{SectionAlphaBlend, SectionMaxComponentBlend} {
+		original, swapped := p.Transforms(section), transposed.Transforms(section)
+
+		for flat := range swapped {
+			level, s := flat/numPaletteColors, flat%numPaletteColors
+
+			for d := range swapped[flat] {
+				swapped[flat][d] = original[level*numPaletteColors+d][s]
+			}
+		}
+	}
+
+	meanDeltaE := func(pl2 *PL2, mode BlendMode) float64 {
+		got, err := Composite(src, dst, pl2, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want, err := CompositeReference(src, dst, pl2, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got.ColorIndexAt(10, 10) != dst.ColorIndexAt(10, 10) || got.ColorIndexAt(0, 0) != dst.ColorIndexAt(0, 0) {
+			t.Fatal("pixels without a visible source should keep the destination")
+		}
+
+		sum := 0.0
+		for y := 8; y < 32; y++ {
+			for x := 8; x < 32; x++ {
+				sum += deltaE(got.At(x, y), want.At(x, y))
+			}
+		}
+
+		return sum / (24 * 24)
+	}
+
+	for _, mode := range []BlendMode{BlendAlpha25, BlendAlpha50, BlendAlpha75, BlendAdditive, BlendMultiplicative, BlendMaxComponent} {
+		t.Run(mode.String(), func(t *testing.T) {
+			got := meanDeltaE(p, mode)
+			if got > 10 {
+				t.Errorf("mean ΔE to the reference is %.2f", got)
+			}
+
+			// symmetric blends do not tell the orientation of their table
+			if mode == BlendAlpha50 || mode == BlendAdditive || mode == BlendMultiplicative {
+				return
+			}
+
+			if swapped := meanDeltaE(transposed, mode); swapped <= got {
+				t.Errorf("transposed tables are closer to the reference, %.2f, than the tables, %.2f", swapped, got)
+			}
+		})
+	}
+}
+
+func TestParseBlendMode(t *testing.T) {
+	for mode := BlendAlpha25; mode <= BlendMaxComponent; mode++ {
+		if got, err := ParseBlendMode(mode.String()); err != nil || got != mode {
+			t.Errorf("ParseBlendMode(%q) = %v, %v", mode.String(), got, err)
+		}
+	}
+
+	if _, err := ParseBlendMode("screen"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestComposite_shortPalette(t *testing.T) {
+	p := Generate(webSafePalette(), VanillaGenerateOptions())
+	p.BasePalette = p.BasePalette[:16]
+
+	img := image.NewPaletted(image.Rect(0, 0, 4, 4), p.BasePalette)
+
+	if _, err := Composite(img, img, p, BlendAlpha50); err == nil {
+		t.Error("expected an error")
+	}
+
+	if _, err := CompositeReference(img, img, p, BlendAlpha50); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,212 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+
+	color2 "github.com/lucasb-eyer/go-colorful"
+)
+
+// CurvePoint is a control point of LightCurvePoints. Levels between control points are
+// interpolated linearly, levels before the first and after the last one take their scale.
+type CurvePoint struct {
+	// Level is the index of the light or inverted color level.
+	Level int `json:"level"`
+	// Scale is the light of the level in [0, 1], or how much colors fade toward white.
+	Scale float64 `json:"scale"`
+}
+
+// levelCurve computes the scales of light or inverted color levels.
+type levelCurve struct {
+	kind   LightCurve
+	shift  uint
+	gamma  float64
+	points []CurvePoint
+	count  int
+}
+
+func (g *generation) lightLevelCurve() levelCurve {
+	return levelCurve{
+		kind:   g.opts.LightCurve,
+		shift:  g.opts.LightLevelShift,
+		gamma:  g.opts.LightGamma,
+		points: g.opts.LightPoints,
+		count:  lightLevelVariations,
+	}
+}
+
+func (g *generation) invColorCurve() levelCurve {
+	return levelCurve{
+		kind:   g.opts.InvColorCurve,
+		shift:  g.opts.InvColorShift,
+		gamma:  g.opts.InvColorGamma,
+		points: g.opts.InvColorPoints,
+		count:  invColorVariations,
+	}
+}
+
+// isShift tells whether the curve is the historical bit shift, computed on integers.
+func (c levelCurve) isShift() bool {
+	return c.kind == "" || c.kind == LightCurveShift
+}
+
+// scale returns the scale of a level. LightCurvePoints without points is LightCurveLinear.
+func (c levelCurve) scale(level int) float64 {
+	ratio := float64(level+1) / float64(c.count)
+
+	switch c.kind {
+	case LightCurveGamma:
+		return math.Pow(ratio, c.gamma)
+	case LightCurveLStar:
+		return lstarToY(ratio * 100)
+	case LightCurvePoints:
+		if len(c.points) > 0 {
+			return interpolatePoints(c.points, level)
+		}
+	case "", LightCurveShift:
+		return float64(level+1) / float64(uint(1)<<c.shift)
+	}
+
+	return ratio
+}
+
+// inLinearLight tells whether the scale applies to linear light components.
+func (c levelCurve) inLinearLight() bool {
+	return c.kind == LightCurveLStar
+}
+
+// apply scales an 8 bit component, or fades it toward white, by the scale of a level. The
+// result is not rounded.
+func (c levelCurve) apply(level int, n uint8, fade bool) float64 {
+	s := c.scale(level)
+
+	if !c.inLinearLight() {
+		v := float64(n)
+
+		if fade {
+			return v + (math.MaxUint8-v)*s
+		}
+
+		return v * s
+	}
+
+	v := linearize(float64(n) / math.MaxUint8)
+
+	if fade {
+		v += (1 - v) * s
+	} else {
+		v *= s
+	}
+
+	return delinearize(math.Min(1, v)) * math.MaxUint8
+}
+
+// round8 rounds a component to 8 bits, clamping it.
+func round8(v float64) uint8 {
+	return uint8(math.Round(math.Min(math.MaxUint8, math.Max(0, v))))
+}
+
+// interpolatePoints interpolates the scale of a level between control points sorted by level.
+func interpolatePoints(points []CurvePoint, level int) float64 {
+	if level <= points[0].Level {
+		return points[0].Scale
+	}
+
+	for idx := 1; idx < len(points); idx++ {
+		from, to := points[idx-1], points[idx]
+
+		if level <= to.Level {
+			t := float64(level-from.Level) / float64(to.Level-from.Level)
+
+			return from.Scale + (to.Scale-from.Scale)*t
+		}
+	}
+
+	return points[len(points)-1].Scale
+}
+
+// checkCurvePoints checks that control points have increasing levels among count levels, and
+// scales in [0, 1].
+func checkCurvePoints(name string, points []CurvePoint, count int) error {
+	if len(points) == 0 {
+		return fmt.Errorf("%s control points are missing", name)
+	}
+
+	for idx, p := range points {
+		if p.Level < 0 || p.Level >= count {
+			return fmt.Errorf("%s control point level %d is not in [0, %d]", name, p.Level, count-1)
+		}
+
+		if idx > 0 && p.Level <= points[idx-1].Level {
+			return fmt.Errorf("%s control point levels must increase, %d follows %d", name, p.Level, points[idx-1].Level)
+		}
+
+		if p.Scale < 0 || p.Scale > 1 {
+			return fmt.Errorf("%s control point scale %v is not in [0, 1]", name, p.Scale)
+		}
+	}
+
+	return nil
+}
+
+// checkLightTint checks that the light tint is empty or a hex color, and that its strength is
+// in [0, 1].
+func checkLightTint(tint string, strength float64) error {
+	if tint != "" {
+		if _, err := color2.Hex(tint); err != nil {
+			return fmt.Errorf("invalid light tint %q, %w", tint, err)
+		}
+	}
+
+	if strength < 0 || strength > 1 {
+		return fmt.Errorf("light tint strength %v is not in [0, 1]", strength)
+	}
+
+	return nil
+}
+
+// lightTint returns the multipliers of the red, green and blue components of lit colors. The
+// tint is checked by checkLightTint before generating.
+func (g *generation) lightTint() [3]float64 {
+	tint := [3]float64{1, 1, 1}
+
+	if g.opts.LightTint == "" {
+		return tint
+	}
+
+	c, _ := color2.Hex(g.opts.LightTint)
+
+	strength := g.opts.LightTintStrength
+	if strength == 0 {
+		strength = 1
+	}
+
+	for idx, v := range [3]float64{c.R, c.G, c.B} {
+		tint[idx] = 1 - strength*(1-v)
+	}
+
+	return tint
+}
+
+// lstarToY returns the relative luminance in [0, 1] of a CIE L* lightness in [0, 100].
+func lstarToY(l float64) float64 {
+	const kappa, epsilon = 24389.0 / 27, 216.0 / 24389
+
+	if y := cube((l + 16) / 116); y > epsilon {
+		return y
+	}
+
+	return l / kappa
+}
+
+// linearize converts an sRGB component in [0, 1] to linear light.
+func linearize(v float64) float64 {
+	r, _, _ := color2.Color{R: v}.LinearRgb()
+
+	return r
+}
+
+// delinearize converts a linear light component in [0, 1] to sRGB.
+func delinearize(v float64) float64 {
+	return color2.LinearRgb(v, 0, 0).R
+}
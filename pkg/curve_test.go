@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestGenerator_lightCurves(t *testing.T) {
+	const black, white = 0, 255
+
+	ramp := []CurvePoint{{Level: 0, Scale: 0}, {Level: 31, Scale: 1}}
+
+	// with the default grayscale palette, palette indices are gray levels
+	tests := []struct {
+		name    string
+		opts    func(opts *GenerateOptions)
+		section SectionID
+		level   int
+		src     int
+		want    int
+	}{
+		{"shift", func(opts *GenerateOptions) {}, SectionLightLevelVariations, 15, white, 127},
+		{"linear", func(opts *GenerateOptions) {
+			opts.LightCurve = LightCurveLinear
+		}, SectionLightLevelVariations, 15, white, 128},
+		{"gamma", func(opts *GenerateOptions) {
+			opts.LightCurve, opts.LightGamma = LightCurveGamma, 2
+		}, SectionLightLevelVariations, 15, white, 64},
+		{"lstar", func(opts *GenerateOptions) {
+			opts.LightCurve = LightCurveLStar
+		}, SectionLightLevelVariations, 15, white, 119},
+		{"points", func(opts *GenerateOptions) {
+			opts.LightCurve, opts.LightPoints = LightCurvePoints, ramp
+		}, SectionLightLevelVariations, 15, white, 123},
+		{"inverted shift", func(opts *GenerateOptions) {}, SectionInvColorVariations, 7, black, 127},
+		{"inverted lstar", func(opts *GenerateOptions) {
+			opts.InvColorCurve = LightCurveLStar
+		}, SectionInvColorVariations, 7, black, 119},
+		{"inverted points", func(opts *GenerateOptions) {
+			opts.InvColorCurve = LightCurvePoints
+			opts.InvColorPoints = []CurvePoint{{Level: 8, Scale: 0.2}}
+		}, SectionInvColorVariations, 0, black, 51},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultGenerateOptions()
+			opts.Sections = []SectionID{tt.section}
+			tt.opts(&opts)
+
+			src := &PL2{}
+			src.allocateTransforms()
+
+			pl2, err := NewGenerator(opts).Regenerate(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := int(pl2.Transforms(tt.section)[tt.level][tt.src])
+
+			if diff := got - tt.want; diff < -1 || diff > 1 {
+				t.Errorf("%s of %d is %d, want %d", tt.section.Section().ElementName(tt.level), tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerator_lightTint(t *testing.T) {
+	p := webSafePalette()
+	white := p.Index(color.RGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF})
+
+	opts := DefaultGenerateOptions()
+	opts.Sections = []SectionID{SectionLightLevelVariations}
+	opts.LightTint = "#ff6600"
+
+	src := &PL2{BasePalette: p}
+	src.allocateTransforms()
+
+	pl2, err := NewGenerator(opts).Regenerate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := color.RGBA{R: 0xFF, G: 0x66, A: 0xFF}
+
+	if got := p[pl2.LightLevelVariations[lightLevelVariations-1][white]]; got != want {
+		t.Errorf("full light of white is %v, want %v", got, want)
+	}
+}
+
+func TestGenerator_invalidLightTint(t *testing.T) {
+	tests := []struct {
+		name     string
+		tint     string
+		strength float64
+	}{
+		{"invalid hex", "#ff66", 0},
+		{"negative strength", "#ff6600", -0.5},
+		{"strength above one", "#ff6600", 1.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultGenerateOptions()
+			opts.LightTint = tt.tint
+			opts.LightTintStrength = tt.strength
+
+			if _, err := NewGenerator(opts).Generate(nil, nil); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestGenerator_invalidCurves(t *testing.T) {
+	tests := []struct {
+		name string
+		opts func(opts *GenerateOptions)
+	}{
+		{"zero light gamma", func(opts *GenerateOptions) { opts.LightCurve = LightCurveGamma }},
+		{"negative inverted gamma", func(opts *GenerateOptions) {
+			opts.InvColorCurve, opts.InvColorGamma = LightCurveGamma, -1
+		}},
+		{"unknown light curve", func(opts *GenerateOptions) { opts.LightCurve = "cubic" }},
+		{"missing inverted points", func(opts *GenerateOptions) { opts.InvColorCurve = LightCurvePoints }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultGenerateOptions()
+			tt.opts(&opts)
+
+			if _, err := NewGenerator(opts).Generate(nil, nil); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -1,183 +1,123 @@
 package pkg
 
 import (
-	"fmt"
+	"bytes"
+	"errors"
 	"image/color"
 	"io"
 	"math"
-
-	"github.com/OpenDiablo2/bitstream"
 )
 
+// Decode reads a PL2 from the stream into the receiver.
 func (pl2 *PL2) Decode(rs io.ReadSeeker) (*PL2, error) {
-	stream := bitstream.NewReader(rs)
-
-	if err := pl2.decodeBasePalette(stream); err != nil {
-		return nil, err
-	}
-
-	if err := pl2.decodeTransforms(stream); err != nil {
-		return nil, err
-	}
-
-	if err := pl2.decodeTextColors(stream); err != nil {
-		return nil, err
-	}
-
-	if err := pl2.decodeTextColorTransforms(stream); err != nil {
+	if _, err := pl2.ReadFrom(rs); err != nil {
 		return nil, err
 	}
 
 	return pl2, nil
 }
 
-func (pl2 *PL2) decodeColors(stream *bitstream.Reader, dst color.Palette, colorBytes int) error {
-	const (
-		rOff, gOff, bOff = 0, 1, 2 // rgb offsets in the returned bytes
-	)
-
-	for idx := range dst {
-		rgba, err := stream.Next(colorBytes).Bytes().AsBytes()
-
-		if err != nil {
-			return fmt.Errorf("could not decode color, %w", err)
-		}
-
-		r, g, b, a := rgba[rOff], rgba[gOff], rgba[bOff], uint8(math.MaxUint8)
-
-		dst[idx] = &color.RGBA{R: r, G: g, B: b, A: a}
-	}
-
-	return nil
-}
-
-func (pl2 *PL2) decodeBasePalette(stream *bitstream.Reader) error {
-	pl2.BasePalette = make(color.Palette, numPaletteColors)
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (pl2 *PL2) UnmarshalBinary(data []byte) error {
+	_, err := pl2.ReadFrom(bytes.NewReader(data))
 
-	return pl2.decodeColors(stream, pl2.BasePalette, 4)
+	return err
 }
 
-func (pl2 *PL2) decodeTextColors(stream *bitstream.Reader) error {
-	pl2.TextColors = make(color.Palette, numTextColors)
-
-	return pl2.decodeColors(stream, pl2.TextColors, 3)
-}
-
-func (pl2 *PL2) decodeTransforms(stream *bitstream.Reader) (err error) {
-	if err = pl2.decodeLightingTransforms(stream); err != nil {
-		return err
+// ReadFrom implements io.ReaderFrom. It reads exactly FileSize bytes from r, one section at a
+// time, and only modifies the receiver when the whole PL2 has been read.
+func (pl2 *PL2) ReadFrom(r io.Reader) (n int64, err error) {
+	decoded := &PL2{
+		BasePalette: make(color.Palette, numPaletteColors),
+		TextColors:  make(color.Palette, numTextColors),
 	}
 
-	if err = pl2.decodeBlendModeTransforms(stream); err != nil {
-		return err
-	}
+	decoded.allocateTransforms()
 
-	if err = pl2.decodeColorVariationTransforms(stream); err != nil {
-		return err
-	}
+	buf := make([]byte, maxSectionSize())
 
-	if err = pl2.decodeOtherTransforms(stream); err != nil {
-		return err
-	}
+	for _, section := range layout {
+		data := buf[:section.Size()]
 
-	return nil
-}
+		read, err := io.ReadFull(r, data)
+		n += int64(read)
 
-func (pl2 *PL2) decodeLightingTransforms(stream *bitstream.Reader) (err error) {
-	pl2.LightLevelVariations = make([]Transform, lightLevelVariations)
-	if err = pl2.decodeTransformMulti(stream, &pl2.LightLevelVariations); err != nil {
-		return err
-	}
+		if err != nil {
+			return n, sectionReadError(section, read, err)
+		}
 
-	pl2.InvColorVariations = make([]Transform, invColorVariations)
-	if err = pl2.decodeTransformMulti(stream, &pl2.InvColorVariations); err != nil {
-		return err
+		switch section.ID {
+		case SectionBasePalette:
+			decodeColors(data, section, decoded.BasePalette, decoded.BasePalettePadding[:])
+		case SectionTextColors:
+			decodeColors(data, section, decoded.TextColors, nil)
+		default:
+			decodeTransforms(data, section, decoded.Transforms(section.ID))
+		}
 	}
 
-	if err = pl2.decodeTransformSingle(stream, &pl2.SelectedUnitShift); err != nil {
-		return err
-	}
+	*pl2 = *decoded
 
-	return nil
+	return n, nil
 }
 
-func (pl2 *PL2) decodeBlendModeTransforms(stream *bitstream.Reader) (err error) {
-	pl2.AlphaBlend = make([][]Transform, alphaBlendCoarse)
-	for blendIdx := range pl2.AlphaBlend {
-		pl2.AlphaBlend[blendIdx] = make([]Transform, alphaBlendFine)
-		if err = pl2.decodeTransformMulti(stream, &pl2.AlphaBlend[blendIdx]); err != nil {
-			return err
+// sectionReadError returns the error for a section of which only the given number of bytes
+// could be read.
+func sectionReadError(section Section, read int, err error) error {
+	idx := read / section.ElementSize
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &TruncatedError{
+			Section: section.Name,
+			Index:   section.Index(idx),
+			Size:    section.ElementSize,
+			Offset:  section.ElementOffset(idx),
+			End:     section.Offset + int64(read),
 		}
 	}
 
-	pl2.AdditiveBlend = make([]Transform, additiveBlends)
-	if err = pl2.decodeTransformMulti(stream, &pl2.AdditiveBlend); err != nil {
-		return err
+	return &DecodeError{
+		Section: section.Name,
+		Index:   section.Index(idx),
+		Offset:  section.ElementOffset(idx),
+		Err:     err,
 	}
-
-	pl2.MultiplicativeBlend = make([]Transform, multiplyBlends)
-
-	return pl2.decodeTransformMulti(stream, &pl2.MultiplicativeBlend)
 }
 
-func (pl2 *PL2) decodeColorVariationTransforms(stream *bitstream.Reader) (err error) {
-	pl2.HueVariations = make([]Transform, hueVariations)
-	if err = pl2.decodeTransformMulti(stream, &pl2.HueVariations); err != nil {
-		return err
-	}
+func maxSectionSize() int64 {
+	size := int64(0)
 
-	if err = pl2.decodeTransformSingle(stream, &pl2.RedTones); err != nil {
-		return err
-	}
-
-	if err = pl2.decodeTransformSingle(stream, &pl2.GreenTones); err != nil {
-		return err
+	for _, section := range layout {
+		if section.Size() > size {
+			size = section.Size()
+		}
 	}
 
-	return pl2.decodeTransformSingle(stream, &pl2.BlueTones)
+	return size
 }
 
-func (pl2 *PL2) decodeOtherTransforms(stream *bitstream.Reader) (err error) {
-	pl2.UnknownVariations = make([]Transform, unknownVariations)
-	if err = pl2.decodeTransformMulti(stream, &pl2.UnknownVariations); err != nil {
-		return err
-	}
+// decodeColors decodes the colors of a palette section. The fourth byte of each base palette
+// color is padding, it is copied to padding.
+func decodeColors(data []byte, section Section, dst color.Palette, padding []uint8) {
+	const (
+		rOff, gOff, bOff, padOff = 0, 1, 2, 3 // rgb and padding offsets in each color
+	)
 
-	pl2.MaxComponentBlend = make([]Transform, maxComponentBlends)
-	if err = pl2.decodeTransformMulti(stream, &pl2.MaxComponentBlend); err != nil {
-		return err
-	}
+	for idx := range dst {
+		rgba := data[idx*section.ElementSize:]
 
-	return pl2.decodeTransformSingle(stream, &pl2.DarkenedColorShift)
-}
+		r, g, b, a := rgba[rOff], rgba[gOff], rgba[bOff], uint8(math.MaxUint8)
 
-func (pl2 *PL2) decodeTransformMulti(stream *bitstream.Reader, dst *[]Transform) error {
-	for idx := range *dst {
-		err := pl2.decodeTransformSingle(stream, &((*dst)[idx]))
+		dst[idx] = &color.RGBA{R: r, G: g, B: b, A: a}
 
-		if err != nil {
-			return err
+		if section.ElementSize == basePaletteColorSize {
+			padding[idx] = rgba[padOff]
 		}
 	}
-
-	return nil
 }
 
-func (pl2 *PL2) decodeTransformSingle(stream *bitstream.Reader, dst *Transform) error {
-	indices, err := stream.Next(numPaletteColors).Bytes().AsBytes()
-	if err != nil {
-		return fmt.Errorf("could not decode transform, %w", err)
-	}
-
-	for idx, paletteIndex := range indices {
-		dst[idx] = paletteIndex
+func decodeTransforms(data []byte, section Section, dst []*Transform) {
+	for idx := range dst {
+		copy(dst[idx][:], data[idx*section.ElementSize:])
 	}
-
-	return nil
 }
-
-func (pl2 *PL2) decodeTextColorTransforms(stream *bitstream.Reader) (err error) {
-	pl2.TextColorShifts = make([]Transform, textShifts)
-
-	return pl2.decodeTransformMulti(stream, &pl2.TextColorShifts)
-}
\ No newline at end of file
@@ -0,0 +1,235 @@
+package pkg
+
+import (
+	"image/color"
+	"sort"
+)
+
+// QuantizationError is the error of a generated transform entry: the ideal color computed by
+// the generator, and the palette color picked for it.
+type QuantizationError struct {
+	// Entry is the palette index transformed by the entry.
+	Entry int
+	// Ideal is the color computed by the generator.
+	Ideal color.RGBA
+	// Chosen is the palette index the entry maps to, after protected indices are applied.
+	Chosen uint8
+	// DeltaE is the CIEDE2000 distance between the ideal and chosen colors, on the usual 0 to
+	// 100 lightness scale.
+	DeltaE float64
+}
+
+// SectionQuantization holds the quantization errors of a generated section.
+type SectionQuantization struct {
+	Section Section
+
+	// Transforms holds the errors of each transform of the section, by flat index. Entries
+	// copied from other ones, and those of unused variations, are not measured and have no
+	// error.
+	Transforms [][]QuantizationError
+
+	// MeanDeltaE and MaxDeltaE summarize the errors of the section.
+	MeanDeltaE float64
+	MaxDeltaE  float64
+}
+
+// QuantizationReport holds the quantization errors of the generated sections, in file order.
+type QuantizationReport struct {
+	Sections []SectionQuantization
+}
+
+// QuantizationHotspot is a transform with entries far from their ideal colors.
+type QuantizationHotspot struct {
+	Section Section
+	// Element is the flat index of the transform in its section.
+	Element int
+	// Count is the number of entries above the threshold, out of Entries measured ones.
+	Count   int
+	Entries int
+	// MaxDeltaE is the largest error of the transform.
+	MaxDeltaE float64
+}
+
+// Hotspots returns the transforms having entries with a ΔE above the threshold, those with the
+// most such entries first. They tell where a palette lacks coverage.
+func (r *QuantizationReport) Hotspots(threshold float64) []QuantizationHotspot {
+	var hotspots []QuantizationHotspot
+
+	for _, section := range r.Sections {
+		for flat, errs := range section.Transforms {
+			h := QuantizationHotspot{Section: section.Section, Element: flat, Entries: len(errs)}
+
+			for _, e := range errs {
+				if e.DeltaE > threshold {
+					h.Count++
+				}
+
+				if e.DeltaE > h.MaxDeltaE {
+					h.MaxDeltaE = e.DeltaE
+				}
+			}
+
+			if h.Count > 0 {
+				hotspots = append(hotspots, h)
+			}
+		}
+	}
+
+	// transforms with the same count keep their file order
+	sort.SliceStable(hotspots, func(i, j int) bool {
+		return hotspots[i].Count > hotspots[j].Count
+	})
+
+	return hotspots
+}
+
+// Diagnose regenerates the given PL2 like Regenerate, and also reports the quantization error of
+// every generated entry.
+func (gen *Generator) Diagnose(src *PL2) (*PL2, *QuantizationReport, error) {
+	pl2 := &PL2{}
+
+	pl2.SetMainPalette(src.BasePalette)
+	pl2.SetTextPalette(src.TextColors)
+
+	return gen.generateReport(pl2, src, true)
+}
+
+// idealTransform holds the ideal colors computed for the entries of a transform.
+type idealTransform struct {
+	colors   [numPaletteColors]color.RGBA64
+	measured [numPaletteColors]bool
+}
+
+// index returns the palette index matching a computed color, for the entry of the transform t.
+// When diagnosing, the computed color is kept to report the quantization error.
+func (g *generation) index(t *Transform, entry int, c color.Color) uint8 {
+	if g.ideal != nil {
+		g.recordIdeal(t, entry, c)
+	}
+
+	return uint8(g.matcher.Index(c))
+}
+
+// recordIdeal keeps the color computed for the entry of the transform t.
+func (g *generation) recordIdeal(t *Transform, entry int, c color.Color) {
+	r, gr, b, _ := c.RGBA()
+
+	g.idealMutex.Lock()
+	defer g.idealMutex.Unlock()
+
+	ideal, found := g.ideal[t]
+	if !found {
+		ideal = &idealTransform{}
+		g.ideal[t] = ideal
+	}
+
+	ideal.colors[entry] = color.RGBA64{R: uint16(r), G: uint16(gr), B: uint16(b), A: 0xFFFF}
+	ideal.measured[entry] = true
+}
+
+// recordingMatcher records the colors a registered variation matches, and the indices found.
+type recordingMatcher struct {
+	Matcher
+	colors  []color.Color
+	indices []uint8
+}
+
+func (m *recordingMatcher) Index(c color.Color) int {
+	idx := m.Matcher.Index(c)
+
+	m.colors = append(m.colors, c)
+	m.indices = append(m.indices, uint8(idx))
+
+	return idx
+}
+
+// recordVariation keeps the colors matched while generating the variation t. A variation
+// matching one color per entry, in order, like mapColors, has each color kept for its entry.
+// Otherwise, each entry gets the last color matched to the index it maps to.
+func (g *generation) recordVariation(t *Transform, m *recordingMatcher) {
+	inOrder := len(m.colors) == numPaletteColors
+
+	for entry := 0; inOrder && entry < numPaletteColors; entry++ {
+		inOrder = m.indices[entry] == t[entry]
+	}
+
+	if inOrder {
+		for entry, c := range m.colors {
+			g.recordIdeal(t, entry, c)
+		}
+
+		return
+	}
+
+	matched := make(map[uint8]color.Color)
+
+	for call, c := range m.colors {
+		matched[m.indices[call]] = c
+	}
+
+	for entry, idx := range t {
+		if c, found := matched[idx]; found {
+			g.recordIdeal(t, entry, c)
+		}
+	}
+}
+
+// quantizationReport measures the errors of the selected sections of the generated PL2, once
+// the generation is over.
+func (g *generation) quantizationReport(selected map[SectionID]bool) *QuantizationReport {
+	report := &QuantizationReport{}
+
+	for _, section := range layout {
+		if !selected[section.ID] {
+			continue
+		}
+
+		transforms := g.pl2.Transforms(section.ID)
+		s := SectionQuantization{Section: section, Transforms: make([][]QuantizationError, len(transforms))}
+		entries := 0
+
+		for flat, t := range transforms {
+			ideal, found := g.ideal[t]
+			if !found {
+				continue
+			}
+
+			for entry, measured := range ideal.measured {
+				if !measured {
+					continue
+				}
+
+				chosen := t[entry]
+				delta := deltaE(ideal.colors[entry], g.pl2.BasePalette[chosen])
+
+				s.Transforms[flat] = append(s.Transforms[flat], QuantizationError{
+					Entry:  entry,
+					Ideal:  to8Bit(ideal.colors[entry]),
+					Chosen: chosen,
+					DeltaE: delta,
+				})
+
+				s.MeanDeltaE += delta
+				entries++
+
+				if delta > s.MaxDeltaE {
+					s.MaxDeltaE = delta
+				}
+			}
+		}
+
+		if entries > 0 {
+			s.MeanDeltaE /= float64(entries)
+		}
+
+		report.Sections = append(report.Sections, s)
+	}
+
+	return report
+}
+
+func to8Bit(c color.RGBA64) color.RGBA {
+	const shift = 8
+
+	return color.RGBA{R: uint8(c.R >> shift), G: uint8(c.G >> shift), B: uint8(c.B >> shift), A: 0xFF}
+}
@@ -0,0 +1,117 @@
+package pkg
+
+import (
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestGenerator_Diagnose(t *testing.T) {
+	src := &PL2{}
+	src.SetMainPalette(randomPalette(rand.New(rand.NewSource(5))))
+	src.SetTextPalette(nil)
+	src.allocateTransforms()
+
+	opts := DefaultGenerateOptions()
+	opts.Sections = []SectionID{SectionLightLevelVariations, SectionHueVariations, SectionUnknownVariations}
+
+	pl2, report, err := NewGenerator(opts).Diagnose(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Sections) != len(opts.Sections) {
+		t.Fatalf("report has %d sections, want %d", len(report.Sections), len(opts.Sections))
+	}
+
+	for _, section := range report.Sections {
+		transforms := pl2.Transforms(section.Section.ID)
+
+		for flat, errs := range section.Transforms {
+			for _, e := range errs {
+				if got := transforms[flat][e.Entry]; got != e.Chosen {
+					t.Fatalf("%s entry %d chose %d, the transform maps it to %d",
+						section.Section.ElementName(flat), e.Entry, e.Chosen, got)
+				}
+			}
+		}
+	}
+
+	// full light leaves colors untouched, so every entry has its ideal color
+	for _, e := range report.Sections[0].Transforms[lightLevelVariations-1] {
+		if e.DeltaE != 0 {
+			t.Fatalf("full light of %d has a ΔE of %v, want 0", e.Entry, e.DeltaE)
+		}
+	}
+
+	// registered variations are measured through the matcher they are given
+	if errs := report.Sections[2].Transforms[0]; len(errs) != numPaletteColors {
+		t.Errorf("unknown variation has %d measured entries, want %d", len(errs), numPaletteColors)
+	}
+
+	hotspots := report.Hotspots(10)
+	if len(hotspots) == 0 {
+		t.Fatal("expected hotspots with a random palette")
+	}
+
+	for idx, h := range hotspots {
+		if h.MaxDeltaE <= 10 {
+			t.Errorf("%s is a hotspot with a max ΔE of %v", h.Section.ElementName(h.Element), h.MaxDeltaE)
+		}
+
+		if idx > 0 && h.Count > hotspots[idx-1].Count {
+			t.Errorf("hotspots are not sorted by count")
+		}
+	}
+}
+
+func TestGenerator_DiagnoseVariation(t *testing.T) {
+	const kind = "test-solid-gray"
+
+	gray := color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xFF}
+
+	// a single match for every entry
+	solid := func(base color.Palette, match Matcher, v Variation) Transform {
+		t := Transform{}
+		idx := uint8(match.Index(gray))
+
+		for entry := range t {
+			t[entry] = idx
+		}
+
+		return t
+	}
+
+	if err := RegisterVariation(kind, solid); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { unregisterVariation(kind) })
+
+	src := &PL2{}
+	src.SetMainPalette(randomPalette(rand.New(rand.NewSource(5))))
+	src.SetTextPalette(nil)
+	src.allocateTransforms()
+
+	opts := DefaultGenerateOptions()
+	opts.Sections = []SectionID{SectionUnknownVariations}
+	opts.UnknownVariations = []Variation{{Kind: kind}}
+
+	pl2, report, err := NewGenerator(opts).Diagnose(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errs := report.Sections[0].Transforms[0]
+	if len(errs) != numPaletteColors {
+		t.Fatalf("variation has %d measured entries, want %d", len(errs), numPaletteColors)
+	}
+
+	want := deltaE(gray, pl2.BasePalette[pl2.UnknownVariations[0][0]])
+
+	for _, e := range errs {
+		if e.Ideal != gray || e.DeltaE != want {
+			t.Fatalf("entry %d has ideal %v and ΔE %v, want %v and %v", e.Entry, e.Ideal, e.DeltaE, gray, want)
+		}
+	}
+}
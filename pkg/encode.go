@@ -6,144 +6,76 @@ import (
 	"io"
 )
 
+// Encode writes the PL2 to the writer.
 func (pl2 *PL2) Encode(w io.Writer) error {
-	if err := pl2.encodeBasePalette(w); err != nil {
-		return err
-	}
-
-	if err := pl2.encodeTransforms(w); err != nil {
-		return err
-	}
+	_, err := pl2.WriteTo(w)
 
-	if err := pl2.encodeTextColors(w); err != nil {
-		return err
-	}
-
-	return pl2.encodeTextColorTransforms(w)
+	return err
 }
 
-func (pl2 *PL2) encodeColors(w io.Writer, src color.Palette, colorBytes int) error {
-	for idx := range src {
-		r, g, b, _ := src[idx].RGBA()
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (pl2 *PL2) MarshalBinary() ([]byte, error) {
+	return ToBytes(pl2)
+}
 
-		if colorBytes == 3 {
-			if _, err := w.Write([]byte{byte(r), byte(g), byte(b)}); err != nil {
-				return fmt.Errorf("could not encode colors, %w", err)
-			}
-		} else {
-			if _, err := w.Write([]byte{byte(r), byte(g), byte(b), 0}); err != nil {
-				return fmt.Errorf("could not encode colors, %w", err)
+// WriteTo implements io.WriterTo. Each section is encoded into a buffer and written with a single
+// call to w.Write, so that w does not need to be buffered. The PL2 is not modified, and may be
+// encoded from several goroutines at once.
+func (pl2 *PL2) WriteTo(w io.Writer) (n int64, err error) {
+	basePalette := mainPalette(pl2.BasePalette) // if nil, generates default
+	textColors := textPalette(pl2.TextColors)   // if nil, generates default
+
+	buf := make([]byte, maxSectionSize())
+
+	for _, section := range layout {
+		data := buf[:section.Size()]
+
+		switch section.ID {
+		case SectionBasePalette:
+			encodeColors(data, section, basePalette, pl2.BasePalettePadding[:])
+		case SectionTextColors:
+			encodeColors(data, section, textColors, nil)
+		default:
+			if err = encodeTransforms(data, section, pl2.Transforms(section.ID)); err != nil {
+				return n, err
 			}
 		}
-	}
-
-	return nil
-}
-
-func (pl2 *PL2) encodeBasePalette(w io.Writer) error {
-	pl2.SetMainPalette(pl2.BasePalette) // if nil, generates default
-
-	return pl2.encodeColors(w, pl2.BasePalette, 4)
-}
-
-func (pl2 *PL2) encodeTextColors(w io.Writer) error {
-	pl2.SetTextPalette(pl2.TextColors) // if nil, generates default
-
-	return pl2.encodeColors(w, pl2.TextColors, 3)
-}
-
-func (pl2 *PL2) encodeTransforms(w io.Writer) (err error) {
-	if err = pl2.encodeLightingTransforms(w); err != nil {
-		return err
-	}
-
-	if err = pl2.encodeBlendModeTransforms(w); err != nil {
-		return err
-	}
-
-	if err = pl2.encodeColorVariationTransforms(w); err != nil {
-		return err
-	}
-
-	return pl2.encodeOtherTransforms(w)
-}
 
-func (pl2 *PL2) encodeLightingTransforms(w io.Writer) (err error) {
-	if err = pl2.encodeTransformMulti(w, &pl2.LightLevelVariations); err != nil {
-		return err
-	}
-
-	if err = pl2.encodeTransformMulti(w, &pl2.InvColorVariations); err != nil {
-		return err
-	}
+		written, writeErr := w.Write(data)
+		n += int64(written)
 
-	return pl2.encodeTransformSingle(w, &pl2.SelectedUnitShift)
-}
-
-func (pl2 *PL2) encodeBlendModeTransforms(w io.Writer) (err error) {
-	for blendIdx := range pl2.AlphaBlend {
-		if err = pl2.encodeTransformMulti(w, &pl2.AlphaBlend[blendIdx]); err != nil {
-			return err
+		if writeErr != nil {
+			return n, fmt.Errorf("could not encode %s, %w", section.Name, writeErr)
 		}
 	}
 
-	if err = pl2.encodeTransformMulti(w, &pl2.AdditiveBlend); err != nil {
-		return err
-	}
-
-	return pl2.encodeTransformMulti(w, &pl2.MultiplicativeBlend)
+	return n, nil
 }
 
-func (pl2 *PL2) encodeColorVariationTransforms(w io.Writer) (err error) {
-	if err = pl2.encodeTransformMulti(w, &pl2.HueVariations); err != nil {
-		return err
-	}
-
-	if err = pl2.encodeTransformSingle(w, &pl2.RedTones); err != nil {
-		return err
-	}
-
-	if err = pl2.encodeTransformSingle(w, &pl2.GreenTones); err != nil {
-		return err
-	}
-
-	return pl2.encodeTransformSingle(w, &pl2.BlueTones)
-}
+// encodeColors encodes the colors of a palette section, the base palette colors are followed by
+// their padding byte.
+func encodeColors(data []byte, section Section, src color.Palette, padding []uint8) {
+	for idx := range src {
+		r, g, b, _ := src[idx].RGBA()
 
-func (pl2 *PL2) encodeOtherTransforms(w io.Writer) (err error) {
-	if err = pl2.encodeTransformMulti(w, &pl2.UnknownVariations); err != nil {
-		return err
-	}
+		rgb := data[idx*section.ElementSize:]
+		rgb[0], rgb[1], rgb[2] = byte(r), byte(g), byte(b)
 
-	if err = pl2.encodeTransformMulti(w, &pl2.MaxComponentBlend); err != nil {
-		return err
+		if section.ElementSize == basePaletteColorSize {
+			rgb[3] = padding[idx]
+		}
 	}
-
-	return pl2.encodeTransformSingle(w, &pl2.DarkenedColorShift)
 }
 
-func (pl2 *PL2) encodeTransformMulti(w io.Writer, src *[]Transform) error {
-	for idx := range *src {
-		err := pl2.encodeTransformSingle(w, &((*src)[idx]))
-
-		if err != nil {
-			return fmt.Errorf("%w", err)
-		}
+func encodeTransforms(data []byte, section Section, src []*Transform) error {
+	if len(src) != section.Count {
+		const fmtErr = "could not encode %s, has %d transforms, expected %d"
+		return fmt.Errorf(fmtErr, section.Name, len(src), section.Count)
 	}
 
-	return nil
-}
-
-func (pl2 *PL2) encodeTransformSingle(w io.Writer, src *Transform) error {
 	for idx := range src {
-		if _, err := w.Write([]byte{src[idx]}); err != nil {
-			return fmt.Errorf("could not encode transform, %w", err)
-		}
+		copy(data[idx*section.ElementSize:], src[idx][:])
 	}
 
 	return nil
 }
-
-func (pl2 *PL2) encodeTextColorTransforms(w io.Writer) (err error) {
-	return pl2.encodeTransformMulti(w, &pl2.TextColorShifts)
-}
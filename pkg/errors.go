@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// TruncatedError is returned when the PL2 data ends before an element of a section could be
+// read in full. Use errors.As to retrieve it from a decode error.
+type TruncatedError struct {
+	// Section is the name of the section being read, eg. "AlphaBlend".
+	Section string
+	// Index is the index of the element within the section, eg. [1 37] for AlphaBlend[1][37].
+	// It is empty for sections holding a single element.
+	Index []int
+	// Size is the expected size of the element, in bytes.
+	Size int
+	// Offset is the offset of the element in the data.
+	Offset int64
+	// End is the offset at which the data ends.
+	End int64
+}
+
+// Element returns the name of the truncated element, eg. "AlphaBlend[1][37]".
+func (e *TruncatedError) Element() string {
+	return elementName(e.Section, e.Index)
+}
+
+func (e *TruncatedError) Error() string {
+	const fmtErr = "file ends inside %s, expected %d bytes at offset %d but data ends at offset %d"
+
+	return fmt.Sprintf(fmtErr, e.Element(), e.Size, e.Offset, e.End)
+}
+
+// Unwrap allows matching a TruncatedError with io.ErrUnexpectedEOF.
+func (e *TruncatedError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+// DecodeError is returned when an element of a section could not be read for any other reason
+// than the data being too short, eg. an I/O error of the underlying reader.
+type DecodeError struct {
+	// Section is the name of the section being read.
+	Section string
+	// Index is the index of the element within the section, empty for single elements.
+	Index []int
+	// Offset is the offset of the element in the data.
+	Offset int64
+	// Err is the underlying error.
+	Err error
+}
+
+// Element returns the name of the element that could not be decoded.
+func (e *DecodeError) Element() string {
+	return elementName(e.Section, e.Index)
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("could not decode %s at offset %d, %v", e.Element(), e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func elementName(section string, index []int) string {
+	sb := &strings.Builder{}
+
+	sb.WriteString(section)
+
+	for _, idx := range index {
+		fmt.Fprintf(sb, "[%d]", idx)
+	}
+
+	return sb.String()
+}
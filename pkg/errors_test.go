@@ -0,0 +1,55 @@
+package pkg
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestDecode_truncated(t *testing.T) {
+	data := randomPL2Bytes(0)
+
+	alphaBlend := SectionAlphaBlend.Section()
+	textColors := SectionTextColors.Section()
+
+	tests := []struct {
+		name    string
+		size    int
+		element string
+		index   []int
+	}{
+		{"empty", 0, "BasePalette[0]", []int{0}},
+		{"inside palette", 10, "BasePalette[2]", []int{2}},
+		{"inside alpha blend", int(alphaBlend.ElementOffset(256+37)) + 12, "AlphaBlend[1][37]", []int{1, 37}},
+		{"inside text colors", int(textColors.End()) - 1, "TextColors[12]", []int{12}},
+		{"inside text color shifts", len(data) - 1, "TextColorShifts[12]", []int{12}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromBytes(data[:tt.size])
+
+			var truncated *TruncatedError
+			if !errors.As(err, &truncated) {
+				t.Fatalf("expected a TruncatedError, got %v", err)
+			}
+
+			if got := truncated.Element(); got != tt.element {
+				t.Errorf("truncated element is %s, want %s", got, tt.element)
+			}
+
+			if !reflect.DeepEqual(truncated.Index, tt.index) {
+				t.Errorf("truncated index is %v, want %v", truncated.Index, tt.index)
+			}
+
+			if truncated.End != int64(tt.size) {
+				t.Errorf("data end is %d, want %d", truncated.End, tt.size)
+			}
+
+			if !errors.Is(err, io.ErrUnexpectedEOF) {
+				t.Errorf("expected error to match io.ErrUnexpectedEOF")
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package pkg
+
+import (
+	"fmt"
+	"image/color"
+	"sort"
+
+	color2 "github.com/lucasb-eyer/go-colorful"
+)
+
+// SectionFidelity tells how close a section of a generated PL2 is to the same section of a
+// reference PL2. Entries of transform sections are the palette indices chosen by each transform,
+// entries of color sections are the colors themselves.
+type SectionFidelity struct {
+	Section Section
+
+	// Entries is the number of compared entries, Matching the number of identical ones
+	Entries  int
+	Matching int
+
+	// MeanDeltaE and MaxDeltaE are the mean and max CIEDE2000 distances between the colors of
+	// the compared entries, on the usual 0 to 100 lightness scale
+	MeanDeltaE float64
+	MaxDeltaE  float64
+
+	// Worst holds the flat indices of the section elements with the highest mean ΔE, worst first.
+	// Elements which match the reference are never listed.
+	Worst []int
+}
+
+// MatchPercent returns the percentage of entries matching the reference.
+func (f SectionFidelity) MatchPercent() float64 {
+	if f.Entries == 0 {
+		return 100
+	}
+
+	return 100 * float64(f.Matching) / float64(f.Entries)
+}
+
+// FidelityReport holds the fidelity of every section, in file order.
+type FidelityReport struct {
+	Sections []SectionFidelity
+}
+
+// MatchPercent returns the percentage of entries matching the reference, over all sections.
+func (r *FidelityReport) MatchPercent() float64 {
+	entries, matching := 0, 0
+
+	for _, f := range r.Sections {
+		entries += f.Entries
+		matching += f.Matching
+	}
+
+	return SectionFidelity{Entries: entries, Matching: matching}.MatchPercent()
+}
+
+// CompareFidelity compares a generated PL2 to a reference one, section by section. At most
+// worst element indices are listed for each section, worst must not be negative.
+func CompareFidelity(reference, generated *PL2, worst int) (*FidelityReport, error) {
+	if worst < 0 {
+		return nil, fmt.Errorf("could not compare, %d worst elements requested", worst)
+	}
+
+	if err := checkComparable(reference, generated); err != nil {
+		return nil, err
+	}
+
+	deltas := newDeltaTable(reference.BasePalette, generated.BasePalette)
+	report := &FidelityReport{Sections: make([]SectionFidelity, 0, NumSections)}
+
+	for _, section := range layout {
+		var elements []elementFidelity
+
+		switch section.ID {
+		case SectionBasePalette:
+			elements = compareColors(reference.BasePalette, generated.BasePalette)
+		case SectionTextColors:
+			elements = compareColors(reference.TextColors, generated.TextColors)
+		default:
+			elements = compareTransforms(reference.Transforms(section.ID), generated.Transforms(section.ID), deltas)
+		}
+
+		report.Sections = append(report.Sections, sectionFidelity(section, elements, worst))
+	}
+
+	return report, nil
+}
+
+func checkComparable(reference, generated *PL2) error {
+	files := []struct {
+		name string
+		pl2  *PL2
+	}{{"reference", reference}, {"generated", generated}}
+
+	for _, file := range files {
+		if len(file.pl2.BasePalette) != numPaletteColors {
+			const fmtErr = "could not compare, %s base palette has %d colors, expected %d"
+			return fmt.Errorf(fmtErr, file.name, len(file.pl2.BasePalette), numPaletteColors)
+		}
+
+		if len(file.pl2.TextColors) != numTextColors {
+			const fmtErr = "could not compare, %s text palette has %d colors, expected %d"
+			return fmt.Errorf(fmtErr, file.name, len(file.pl2.TextColors), numTextColors)
+		}
+
+		for _, section := range layout {
+			if !section.ID.IsTransforms() {
+				continue
+			}
+
+			if count := len(file.pl2.Transforms(section.ID)); count != section.Count {
+				const fmtErr = "could not compare %s, %s file has %d transforms, expected %d"
+				return fmt.Errorf(fmtErr, section.Name, file.name, count, section.Count)
+			}
+		}
+	}
+
+	return nil
+}
+
+// elementFidelity is the comparison of a single section element
+type elementFidelity struct {
+	entries  int
+	matching int
+	sumDelta float64
+	maxDelta float64
+}
+
+func (e *elementFidelity) add(delta float64, matching bool) {
+	e.entries++
+	e.sumDelta += delta
+
+	if matching {
+		e.matching++
+	}
+
+	if delta > e.maxDelta {
+		e.maxDelta = delta
+	}
+}
+
+func compareColors(reference, generated color.Palette) []elementFidelity {
+	elements := make([]elementFidelity, len(reference))
+
+	for idx := range reference {
+		r1, g1, b1, _ := reference[idx].RGBA()
+		r2, g2, b2, _ := generated[idx].RGBA()
+
+		elements[idx].add(deltaE(reference[idx], generated[idx]), r1 == r2 && g1 == g2 && b1 == b2)
+	}
+
+	return elements
+}
+
+func compareTransforms(reference, generated []*Transform, deltas *deltaTable) []elementFidelity {
+	elements := make([]elementFidelity, len(reference))
+
+	for idx := range reference {
+		for palIdx, refIdx := range reference[idx] {
+			genIdx := generated[idx][palIdx]
+			elements[idx].add(deltas.get(refIdx, genIdx), refIdx == genIdx)
+		}
+	}
+
+	return elements
+}
+
+func sectionFidelity(section Section, elements []elementFidelity, worst int) SectionFidelity {
+	f := SectionFidelity{Section: section}
+	candidates := make([]int, 0, len(elements))
+
+	for idx, e := range elements {
+		f.Entries += e.entries
+		f.Matching += e.matching
+		f.MeanDeltaE += e.sumDelta
+
+		if e.maxDelta > f.MaxDeltaE {
+			f.MaxDeltaE = e.maxDelta
+		}
+
+		if e.matching < e.entries {
+			candidates = append(candidates, idx)
+		}
+	}
+
+	if f.Entries > 0 {
+		f.MeanDeltaE /= float64(f.Entries)
+	}
+
+	// elements with the same mean keep their file order
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ei, ej := elements[candidates[i]], elements[candidates[j]]
+		return ei.sumDelta/float64(ei.entries) > ej.sumDelta/float64(ej.entries)
+	})
+
+	if len(candidates) > worst {
+		candidates = candidates[:worst]
+	}
+
+	f.Worst = candidates
+
+	return f
+}
+
+// deltaTable lazily computes the ΔE between reference and generated palette colors
+type deltaTable struct {
+	reference []color2.Color
+	generated []color2.Color
+	computed  [numPaletteColors * numPaletteColors]bool
+	deltas    [numPaletteColors * numPaletteColors]float64
+}
+
+func newDeltaTable(reference, generated color.Palette) *deltaTable {
+	t := &deltaTable{
+		reference: make([]color2.Color, len(reference)),
+		generated: make([]color2.Color, len(generated)),
+	}
+
+	for idx := range reference {
+		t.reference[idx] = toColorful(reference[idx])
+		t.generated[idx] = toColorful(generated[idx])
+	}
+
+	return t
+}
+
+func (t *deltaTable) get(refIdx, genIdx uint8) float64 {
+	key := int(refIdx)*numPaletteColors + int(genIdx)
+
+	if !t.computed[key] {
+		t.deltas[key] = scaledDeltaE(t.reference[refIdx], t.generated[genIdx])
+		t.computed[key] = true
+	}
+
+	return t.deltas[key]
+}
+
+func deltaE(c1, c2 color.Color) float64 {
+	return scaledDeltaE(toColorful(c1), toColorful(c2))
+}
+
+// scaledDeltaE returns the CIEDE2000 distance on the usual 0 to 100 lightness scale, go-colorful
+// uses a 0 to 1 scale.
+func scaledDeltaE(c1, c2 color2.Color) float64 {
+	const scale = 100
+
+	return scale * c1.DistanceCIEDE2000(c2)
+}
@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"testing"
+)
+
+func TestCompareFidelity(t *testing.T) {
+	reference, err := FromBytes(randomPL2Bytes(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(p *PL2)
+		section SectionID
+		match   float64
+		worst   []int
+	}{
+		{"identical", func(p *PL2) {}, SectionHueVariations, 100, []int{}},
+		{"one entry", func(p *PL2) {
+			p.HueVariations[7][3] = p.HueVariations[7][3] + 1
+		}, SectionHueVariations, 100 * (1 - 1/float64(hueVariations*numPaletteColors)), []int{7}},
+		{"worst first", func(p *PL2) {
+			p.AlphaBlend[1][2][0]++
+			p.AlphaBlend[2][5] = p.AlphaBlend[0][0]
+		}, SectionAlphaBlend, -1, []int{2*alphaBlendFine + 5, alphaBlendFine + 2}},
+		{"text color", func(p *PL2) {
+			p.TextColors[4] = p.TextColors[5]
+		}, SectionTextColors, 100 * 12 / float64(numTextColors), []int{4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generated := reference.Clone()
+			tt.modify(generated)
+
+			report, err := CompareFidelity(reference, generated, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := report.Sections[tt.section]
+
+			if f.Section.ID != tt.section {
+				t.Fatalf("section %d is %s", tt.section, f.Section.Name)
+			}
+
+			if tt.match >= 0 && f.MatchPercent() != tt.match {
+				t.Errorf("match is %v%%, want %v%%", f.MatchPercent(), tt.match)
+			}
+
+			if len(f.Worst) != len(tt.worst) {
+				t.Fatalf("worst is %v, want %v", f.Worst, tt.worst)
+			}
+
+			for idx := range tt.worst {
+				if f.Worst[idx] != tt.worst[idx] {
+					t.Errorf("worst is %v, want %v", f.Worst, tt.worst)
+				}
+			}
+
+			if len(tt.worst) == 0 && (f.MeanDeltaE != 0 || f.MaxDeltaE != 0) {
+				t.Errorf("ΔE is %v mean, %v max, want 0", f.MeanDeltaE, f.MaxDeltaE)
+			}
+		})
+	}
+}
+
+func TestCompareFidelity_negativeWorst(t *testing.T) {
+	reference, err := FromBytes(randomPL2Bytes(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CompareFidelity(reference, reference.Clone(), -1); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,320 @@
+package pkg
+
+import (
+	"image/color"
+	"math"
+	"sync"
+)
+
+// FitGenerateOptions estimates the generation options which best reproduce the transforms of the
+// given PL2 from its palettes. Starting from base, parameters are searched one after the other,
+// and each one keeps the value whose generated sections are closest to the file, as measured
+// by the mean ΔE of CompareFidelity. Ties keep the earliest value, base values first.
+// The Workers option of base is used for every generation, its Sections option is ignored.
+func FitGenerateOptions(src *PL2, base GenerateOptions) (GenerateOptions, error) {
+	if err := checkComparable(src, src); err != nil {
+		return base, err
+	}
+
+	if base.Metric == nil {
+		base.Metric = MetricRGB
+	}
+
+	f := &fitter{src: src, opts: base}
+	f.opts.Sections = nil
+
+	if err := f.fitModeAndMetric(); err != nil {
+		return base, err
+	}
+
+	for _, p := range fitParameters {
+		if err := f.fit(p); err != nil {
+			return base, err
+		}
+	}
+
+	f.opts.Metric = f.opts.Metric.(*cachedMetric).metric
+
+	return f.opts, nil
+}
+
+// fitParameter is a generation option searched by FitGenerateOptions. Discrete parameters try
+// each of their values, continuous ones are searched on a grid between the first and last
+// values, which is then refined around the best value.
+type fitParameter struct {
+	sections   []SectionID
+	values     []float64
+	continuous bool
+	get        func(opts *GenerateOptions) float64
+	set        func(opts *GenerateOptions, v float64)
+}
+
+const (
+	fitGridPoints  = 7
+	fitRefinements = 2
+	fitMaxDecimals = 3
+	fitMaxShift    = 8
+)
+
+func shiftValues() []float64 {
+	values := make([]float64, fitMaxShift+1)
+
+	for shift := range values {
+		values[shift] = float64(shift)
+	}
+
+	return values
+}
+
+// fitParameters are searched in order, the hue step before the saturation and lightness of the
+// hue variations
+var fitParameters = []fitParameter{
+	{
+		sections: []SectionID{SectionLightLevelVariations},
+		values:   shiftValues(),
+		get:      func(opts *GenerateOptions) float64 { return float64(opts.LightLevelShift) },
+		set:      func(opts *GenerateOptions, v float64) { opts.LightLevelShift = uint(v) },
+	},
+	{
+		sections: []SectionID{SectionInvColorVariations},
+		values:   shiftValues(),
+		get:      func(opts *GenerateOptions) float64 { return float64(opts.InvColorShift) },
+		set:      func(opts *GenerateOptions, v float64) { opts.InvColorShift = uint(v) },
+	},
+	{
+		sections:   []SectionID{SectionSelectedUnitShift},
+		values:     []float64{0, 1},
+		continuous: true,
+		get:        func(opts *GenerateOptions) float64 { return opts.SelectedUnitLuminosity },
+		set:        func(opts *GenerateOptions, v float64) { opts.SelectedUnitLuminosity = v },
+	},
+	{
+		// the last alpha blend level is opaque at 1/3
+		sections:   []SectionID{SectionAlphaBlend},
+		values:     []float64{0, 1.0 / alphaBlendCoarse},
+		continuous: true,
+		get:        func(opts *GenerateOptions) float64 { return opts.AlphaStep },
+		set:        func(opts *GenerateOptions, v float64) { opts.AlphaStep = v },
+	},
+	{
+		sections:   []SectionID{SectionHueVariations},
+		values:     []float64{0, 2 * maxDegrees / float64(hueSteps)},
+		continuous: true,
+		get:        func(opts *GenerateOptions) float64 { return opts.HueStep },
+		set:        func(opts *GenerateOptions, v float64) { opts.HueStep = v },
+	},
+	{
+		sections:   []SectionID{SectionHueVariations},
+		values:     []float64{0, 1},
+		continuous: true,
+		get:        func(opts *GenerateOptions) float64 { return opts.HueSaturation },
+		set:        func(opts *GenerateOptions, v float64) { opts.HueSaturation = v },
+	},
+	{
+		sections:   []SectionID{SectionHueVariations},
+		values:     []float64{0, 0.5},
+		continuous: true,
+		get:        func(opts *GenerateOptions) float64 { return opts.HueDarken },
+		set:        func(opts *GenerateOptions, v float64) { opts.HueDarken = v },
+	},
+	{
+		sections:   []SectionID{SectionHueVariations},
+		values:     []float64{0, 0.5},
+		continuous: true,
+		get:        func(opts *GenerateOptions) float64 { return opts.HueBrighten },
+		set:        func(opts *GenerateOptions, v float64) { opts.HueBrighten = v },
+	},
+}
+
+// modeSections do not depend on any other parameter than the mode and metric
+var modeSections = []SectionID{
+	SectionRedTones,
+	SectionGreenTones,
+	SectionBlueTones,
+	SectionDarkenedColorShift,
+	SectionTextColorShifts,
+}
+
+// fitter holds the options being fitted, their metric is a *cachedMetric
+type fitter struct {
+	src  *PL2
+	opts GenerateOptions
+}
+
+func (f *fitter) fitModeAndMetric() error {
+	type candidate struct {
+		mode   GenerationMode
+		metric Metric
+	}
+
+	metrics := []Metric{newCachedMetric(f.opts.Metric)}
+
+	for _, name := range MetricNames() {
+		metric, _ := MetricByName(name)
+		metrics = append(metrics, newCachedMetric(metric))
+	}
+
+	candidates := []candidate{{f.opts.Mode, metrics[0]}}
+
+	for _, mode := range []GenerationMode{ModeDefault, ModeVanilla} {
+		for _, metric := range metrics[1:] {
+			candidates = append(candidates, candidate{mode, metric})
+		}
+	}
+
+	best, bestScore := 0, math.Inf(1)
+
+	for idx, c := range candidates {
+		opts := f.opts
+		opts.Mode, opts.Metric = c.mode, c.metric
+
+		score, err := f.score(opts, modeSections)
+		if err != nil {
+			return err
+		}
+
+		if score < bestScore {
+			best, bestScore = idx, score
+		}
+	}
+
+	f.opts.Mode, f.opts.Metric = candidates[best].mode, candidates[best].metric
+
+	return nil
+}
+
+func (f *fitter) fit(p fitParameter) error {
+	best := p.get(&f.opts)
+
+	bestScore, err := f.scoreValue(p, best)
+	if err != nil {
+		return err
+	}
+
+	try := func(values []float64) error {
+		for _, v := range values {
+			score, err := f.scoreValue(p, v)
+			if err != nil {
+				return err
+			}
+
+			if score < bestScore {
+				best, bestScore = v, score
+			}
+		}
+
+		return nil
+	}
+
+	if !p.continuous {
+		if err := try(p.values); err != nil {
+			return err
+		}
+
+		p.set(&f.opts, best)
+
+		return nil
+	}
+
+	min, max := p.values[0], p.values[len(p.values)-1]
+	low, high := min, max
+
+	for refinement := 0; refinement <= fitRefinements; refinement++ {
+		step := (high - low) / (fitGridPoints - 1)
+		grid := make([]float64, fitGridPoints)
+
+		for idx := range grid {
+			grid[idx] = low + float64(idx)*step
+		}
+
+		if err := try(grid); err != nil {
+			return err
+		}
+
+		low, high = math.Max(min, best-step), math.Min(max, best+step)
+	}
+
+	// options are usually round numbers, which are kept when they do as well
+	for decimals := 0; decimals <= fitMaxDecimals; decimals++ {
+		scale := math.Pow10(decimals)
+		v := math.Round(best*scale) / scale
+
+		score, err := f.scoreValue(p, v)
+		if err != nil {
+			return err
+		}
+
+		if score <= bestScore {
+			best, bestScore = v, score
+			break
+		}
+	}
+
+	p.set(&f.opts, best)
+
+	return nil
+}
+
+func (f *fitter) scoreValue(p fitParameter, v float64) (float64, error) {
+	opts := f.opts
+	p.set(&opts, v)
+
+	return f.score(opts, p.sections)
+}
+
+// score returns the mean ΔE of the given sections, generated with the given options.
+func (f *fitter) score(opts GenerateOptions, sections []SectionID) (float64, error) {
+	opts.Sections = sections
+
+	generated, err := NewGenerator(opts).Regenerate(f.src)
+	if err != nil {
+		return 0, err
+	}
+
+	report, err := CompareFidelity(f.src, generated, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	entries, sum := 0, 0.0
+
+	for _, id := range sections {
+		section := report.Sections[id]
+		entries += section.Entries
+		sum += section.MeanDeltaE * float64(section.Entries)
+	}
+
+	return sum / float64(entries), nil
+}
+
+// cachedMetric shares matchers between the generations of a fit, which all match colors against
+// the same palettes.
+type cachedMetric struct {
+	metric   Metric
+	mutex    sync.Mutex
+	matchers map[string]Matcher
+}
+
+func newCachedMetric(m Metric) *cachedMetric {
+	return &cachedMetric{metric: m, matchers: make(map[string]Matcher)}
+}
+
+func (m *cachedMetric) Matcher(p color.Palette) Matcher {
+	key := make([]byte, 0, len(p)*8)
+
+	for _, c := range p {
+		r, g, b, a := c.RGBA()
+		key = append(key, uint8(r>>8), uint8(r), uint8(g>>8), uint8(g), uint8(b>>8), uint8(b), uint8(a>>8), uint8(a))
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	matcher, found := m.matchers[string(key)]
+	if !found {
+		matcher = m.metric.Matcher(p)
+		m.matchers[string(key)] = matcher
+	}
+
+	return matcher
+}
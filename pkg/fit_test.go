@@ -0,0 +1,48 @@
+package pkg
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestFitGenerateOptions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("fitting generates sections many times")
+	}
+
+	want := VanillaGenerateOptions()
+	want.Metric = MetricOKLab
+	want.LightLevelShift = 4
+	want.InvColorShift = 3
+	want.SelectedUnitLuminosity = 0.3
+	want.AlphaStep = 0.3
+	want.HueStep = 12
+	want.HueDarken = 0.15
+
+	src := Generate(randomPalette(rand.New(rand.NewSource(5))), want)
+
+	got, err := FitGenerateOptions(src, DefaultGenerateOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Mode != want.Mode || got.LightLevelShift != want.LightLevelShift || got.InvColorShift != want.InvColorShift {
+		t.Errorf("fitted mode %v and shifts %d, %d, want %v and %d, %d", got.Mode,
+			got.LightLevelShift, got.InvColorShift, want.Mode, want.LightLevelShift, want.InvColorShift)
+	}
+
+	if name, _ := MetricName(got.Metric); name != "oklab" {
+		t.Errorf("fitted metric %q, want oklab", name)
+	}
+
+	report, err := CompareFidelity(src, Generate(src.BasePalette, got), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const minMatch = 99.9
+
+	if match := report.MatchPercent(); match < minMatch {
+		t.Errorf("regenerating with %+v matches %.2f%% of the file, want at least %v%%", got, match, minMatch)
+	}
+}
@@ -3,48 +3,48 @@ package pkg
 import (
 	"image/color"
 	"math"
-	"fmt"
 
 	color2 "github.com/lucasb-eyer/go-colorful"
 )
 
-func (pl2 *PL2) regenerate() {
-	pl2.SetMainPalette(pl2.BasePalette)
-	pl2.SetTextPalette(pl2.TextColors)
-	pl2.generateTransforms()
-	pl2.generateTextColorTransforms()
-}
+func (g *generation) generateLightLevelVariations() {
+	curve, tint := g.lightLevelCurve(), g.lightTint()
 
-func (pl2 *PL2) generateTransforms() {
-	pl2.generateLightingTransforms()
-	pl2.generateBlendModeTransforms()
-	pl2.generateColorVariationTransforms()
-	pl2.generateOtherTransforms()
-}
+	if !curve.isShift() || tint != [3]float64{1, 1, 1} {
+		g.pl2.LightLevelVariations = g.applyComponentVariations(lightLevelVariations,
+			func(idx, component int, n uint8) uint8 {
+				return round8(curve.apply(idx, n, false) * tint[component])
+			})
 
-func (pl2 *PL2) generateLightingTransforms() {
-	pl2.generateLightLevelVariations()
-	pl2.generateInvColorVariations()
-	pl2.generateSelectedUnitTransforms()
-}
+		return
+	}
+
+	divisor := g.opts.LightLevelShift
 
-func (pl2 *PL2) generateLightLevelVariations() {
 	fnTransform := func(idx int, n uint8) uint8 {
-		const divisor = 5
 		return uint8((uint32(idx) + 1) * uint32(n) >> divisor)
 	}
 
-	pl2.LightLevelVariations = pl2.applyVariations(lightLevelVariations, fnTransform)
+	g.pl2.LightLevelVariations = g.applyVariations(lightLevelVariations, fnTransform)
 }
 
-func (pl2 *PL2) generateInvColorVariations() {
-	fnTransform := func(idx int, n uint8) uint8 {
-		const reduce = 4
+func (g *generation) generateInvColorVariations() {
+	if curve := g.invColorCurve(); !curve.isShift() {
+		g.pl2.InvColorVariations = g.applyComponentVariations(invColorVariations,
+			func(idx, _ int, n uint8) uint8 {
+				return round8(curve.apply(idx, n, true))
+			})
+
+		return
+	}
+
+	reduce := g.opts.InvColorShift
 
+	fnTransform := func(idx int, n uint8) uint8 {
 		return uint8((uint32(idx+1)*uint32(math.MaxUint8-n))>>reduce + uint32(n))
 	}
 
-	pl2.InvColorVariations = pl2.applyVariations(invColorVariations, fnTransform)
+	g.pl2.InvColorVariations = g.applyVariations(invColorVariations, fnTransform)
 }
 
 func rgba2hsl(c color.Color) color2.Color {
@@ -53,342 +53,309 @@ func rgba2hsl(c color.Color) color2.Color {
 	return c2
 }
 
-func (pl2 *PL2) getHSLColors() []color2.Color {
-	if pl2.hslColorsBuffer != nil {
-		return pl2.hslColorsBuffer
-	}
-
-	hslColors := make([]color2.Color, numPaletteColors)
+// getHSLColors returns the base palette colors converted once for the whole generation, it is
+// safe to call from several section generators at once.
+func (g *generation) getHSLColors() []color2.Color {
+	g.hslColorsOnce.Do(func() {
+		hslColors := make([]color2.Color, numPaletteColors)
 
-	for idx := range hslColors {
-		hslColors[idx] = rgba2hsl(pl2.BasePalette[idx])
-	}
+		for idx := range hslColors {
+			hslColors[idx] = rgba2hsl(g.pl2.BasePalette[idx])
+		}
 
-	pl2.hslColorsBuffer = hslColors
+		g.hslColorsBuffer = hslColors
+	})
 
-	return hslColors
+	return g.hslColorsBuffer
 }
 
-func (pl2 *PL2) generateSelectedUnitTransforms() {
-	hslColors := pl2.getHSLColors()
+func (g *generation) generateSelectedUnitTransforms() {
+	hslColors := g.getHSLColors()
 
-	// for each, we increase luminosity by 20%
-	const luminosity20pct = 0.2
+	// for each, we increase luminosity, by 20% by default
+	luminosity := g.opts.SelectedUnitLuminosity
 
 	for idx := range hslColors {
 		h, s, l := hslColors[idx].Hsl()
 
 		if l != 0 {
-			l = math.Min(1.0, l+luminosity20pct)
+			l = math.Min(1.0, l+luminosity)
 		}
 
 		c := color2.Hsl(h, s, l)
 
-		pl2.SelectedUnitShift[idx] = uint8(pl2.BasePalette.Index(c))
+		g.pl2.SelectedUnitShift[idx] = g.index(&g.pl2.SelectedUnitShift, idx, c)
 	}
 }
 
-func getBlendRatio(blendLevel int) float64 {
-	const blendStep25pct float64 = 0.25 // makes the blend step increment 25% per level
+func (g *generation) getBlendRatio(blendLevel int) float64 {
+	if blendLevel < len(g.opts.AlphaRatios) {
+		return g.opts.AlphaRatios[blendLevel]
+	}
 
 	if blendLevel > 3 || blendLevel < 0 {
 		blendLevel = 0
 	}
 
-	return blendStep25pct * float64(blendLevel+1)
+	// the blend step increments 25% per level by default
+	return g.opts.AlphaStep * float64(blendLevel+1)
 }
 
-func (pl2 *PL2) generateBlendModeTransforms() {
-	pl2.generateAlphaTransforms()
-	pl2.generateAdditiveTransforms()
-	pl2.generateMultiplicativeTransforms()
-}
+func (g *generation) generateAlphaTransforms() {
+	g.pl2.AlphaBlend = make([][]Transform, alphaBlendCoarse)
 
-func (pl2 *PL2) generateAlphaTransforms() {
-	pl2.AlphaBlend = make([][]Transform, alphaBlendCoarse)
+	ops := make([]blendOp, alphaBlendCoarse)
 
-	for blendIdx := range pl2.AlphaBlend {
-		pl2.AlphaBlend[blendIdx] = make([]Transform, alphaBlendFine)
+	for blendIdx := range g.pl2.AlphaBlend {
+		g.pl2.AlphaBlend[blendIdx] = make([]Transform, alphaBlendFine)
 
-		blend := getBlendRatio(blendIdx)
+		blend := g.getBlendRatio(blendIdx)
 		inverted := 1 - blend
 
-		fn := func(src, dst uint8) uint8 {
-			componentA := uint8(inverted * float64(dst))
-			componentB := uint8(blend * float64(src))
+		ops[blendIdx] = blendOp{
+			srgb: func(src, dst uint8) uint8 {
+				componentA := uint8(inverted * float64(dst))
+				componentB := uint8(blend * float64(src))
 
-			return componentA + componentB
+				return componentA + componentB
+			},
+			weight: func(_, _ int) float64 {
+				return blend
+			},
 		}
+	}
 
-		for src := range pl2.BasePalette {
-			for dst := range pl2.AlphaBlend[blendIdx] {
-				pl2.AlphaBlend[blendIdx][src][dst] = pl2.getClosestBlendIndex(src, dst, fn)
-			}
+	g.parallel(alphaBlendCoarse*numPaletteColors, func(row int) {
+		blendIdx, src := row/numPaletteColors, row%numPaletteColors
+
+		for dst := range g.pl2.AlphaBlend[blendIdx] {
+			t := &g.pl2.AlphaBlend[blendIdx][src]
+			t[dst] = g.getClosestBlendIndex(t, src, dst, ops[blendIdx])
 		}
-	}
+	})
 }
 
-func (pl2 *PL2) generateAdditiveTransforms() {
-	pl2.AdditiveBlend = make([]Transform, additiveBlends)
+func (g *generation) generateAdditiveTransforms() {
+	g.pl2.AdditiveBlend = make([]Transform, additiveBlends)
 
-	fn := func(src, dst uint8) uint8 {
-		sum := int(src) + int(dst)
+	op := blendOp{
+		srgb: func(src, dst uint8) uint8 {
+			sum := int(src) + int(dst)
 
-		if sum > math.MaxUint8 {
-			sum = math.MaxUint8
-		}
+			if sum > math.MaxUint8 {
+				sum = math.MaxUint8
+			}
 
-		return uint8(sum)
+			return uint8(sum)
+		},
+		linear: func(s, d float64) float64 {
+			return s + d
+		},
 	}
 
-	for _ = range pl2.AdditiveBlend {
-		for dstIndex := range pl2.BasePalette {
-			for srcIndex := range pl2.BasePalette {
-				pl2.AdditiveBlend[srcIndex][dstIndex] = pl2.getClosestBlendIndex(srcIndex, dstIndex, fn)
-			}
+	g.parallel(numPaletteColors, func(dstIndex int) {
+		for srcIndex := range g.pl2.BasePalette {
+			t := &g.pl2.AdditiveBlend[srcIndex]
+			t[dstIndex] = g.getClosestBlendIndex(t, srcIndex, dstIndex, op)
 		}
-	}
+	})
 }
 
-func (pl2 *PL2) generateMultiplicativeTransforms() {
-	pl2.MultiplicativeBlend = make([]Transform, multiplyBlends)
+func (g *generation) generateMultiplicativeTransforms() {
+	g.pl2.MultiplicativeBlend = make([]Transform, multiplyBlends)
 
-	fn := func(src, dst uint8) uint8 {
-		return uint8((float64(src) * float64(dst)) / math.MaxUint8)
+	op := blendOp{
+		srgb: func(src, dst uint8) uint8 {
+			return uint8((float64(src) * float64(dst)) / math.MaxUint8)
+		},
+		linear: func(s, d float64) float64 {
+			return s * d
+		},
 	}
 
-	for _ = range pl2.MultiplicativeBlend {
-		for dstIndex := range pl2.BasePalette {
-			for srcIndex := range pl2.BasePalette {
-				pl2.MultiplicativeBlend[dstIndex][srcIndex] = pl2.getClosestBlendIndex(srcIndex, dstIndex, fn)
-			}
+	// the blend is symmetric, so this is also [dst][src]
+	g.parallel(numPaletteColors, func(srcIndex int) {
+		for dstIndex := range g.pl2.BasePalette {
+			t := &g.pl2.MultiplicativeBlend[srcIndex]
+			t[dstIndex] = g.getClosestBlendIndex(t, srcIndex, dstIndex, op)
 		}
-	}
-}
-
-func (pl2 *PL2) generateColorVariationTransforms() {
-	pl2.generateHueTransforms()
-	pl2.generateRGBTransforms()
+	})
 }
 
 const (
-	hueSteps           int     = 24
-	maxDegrees         float64 = 360
-	hueRotationPerStep float64 = 15 // degrees, normalized
+	hueSteps   int     = 24
+	maxDegrees float64 = 360
 )
 
 // we're gonna be using normalized values for HSL shit because the library we are using
 // implemented hsl with normalized values between 0 and 1.
-func (pl2 *PL2) generateHueTransforms() {
-	pl2.HueVariations = make([]Transform, hueVariations)
-
-	trsIdx := 0
-
-	// Index 1 - 24: Hueshift
-	for shiftIdx := 0; shiftIdx < hueSteps; shiftIdx++ {
-		for palIdx := 0; palIdx < 256; palIdx++ {
-			hslColors := pl2.getHSLColors()
-			h, s, l := hslColors[palIdx].Hsl()
-
-			h += float64(shiftIdx) * hueRotationPerStep
+func (g *generation) generateHueTransforms() {
+	g.pl2.HueVariations = make([]Transform, hueVariations)
 
-			if h > maxDegrees {
-				h -= maxDegrees
-			}
-
-			pl2.HueVariations[trsIdx][palIdx] = uint8(pl2.BasePalette.Index(color2.Hsl(h, s, l)))
-		}
-
-		trsIdx++
+	groups := g.opts.HueGroups
+	if groups == nil {
+		groups = DefaultHueGroups(g.opts)
 	}
 
-	// Index 25 - 48: Hueshift + Darken
-	for shiftIdx := 0; shiftIdx < hueSteps; shiftIdx++ {
-		for palIdx := 0; palIdx < 256; palIdx++ {
-			hslColors := pl2.getHSLColors()
-
-			h, s, l := hslColors[palIdx].Hsl()
-
-			h += float64(shiftIdx) * hueRotationPerStep
-			if h > maxDegrees {
-				h -= maxDegrees
-			}
-
-			s = 0.5
-
-			l -= 0.1
+	hslColors := g.getHSLColors()
+	trsIdx := 0
 
-			if l < 0 {
-				l = 0
+	for _, group := range groups {
+		for shiftIdx := 0; shiftIdx < group.Count && trsIdx < hueVariations; shiftIdx++ {
+			switch {
+			case group.VanillaOnly && g.opts.Mode != ModeVanilla:
+			case group.Variation != nil:
+				g.generateVariation(&g.pl2.HueVariations[trsIdx], *group.Variation)
+			default:
+				g.generateHueVariation(trsIdx, shiftIdx, group, hslColors)
 			}
 
-			pl2.HueVariations[trsIdx][palIdx] = uint8(pl2.BasePalette.Index(color2.Hsl(h, s, l)))
+			trsIdx++
 		}
-
-		trsIdx++
 	}
+}
 
-	// Index 49 - 72: Hueshift + Brighten
-	for shiftIdx := 0; shiftIdx < hueSteps; shiftIdx++ {
-		for palIdx := 0; palIdx < 256; palIdx++ {
-			hslColors := pl2.getHSLColors()
-			h, s, l := hslColors[palIdx].Hsl()
-
-			h += float64(shiftIdx) * hueRotationPerStep
-			for h > maxDegrees {
-				h -= maxDegrees
-			}
-
-			s = 0.5
-
-			l += 0.2
-
-			if l > 1 {
-				l = 1
-			}
-
-			pl2.HueVariations[trsIdx][palIdx] = uint8(pl2.BasePalette.Index(color2.Hsl(h, s, l)))
-		}
-
-		trsIdx++
+func (g *generation) generateHueVariation(trsIdx, shiftIdx int, group HueGroup, hslColors []color2.Color) {
+	divisor := group.LightnessDivisor
+	if divisor == 0 {
+		divisor = 1
 	}
 
-	hslColors := pl2.getHSLColors()
-
-	// Index 73: Grayscale (Revives)
-	for palIdx := 0; palIdx < 256; palIdx++ {
-		H, S, L := hslColors[palIdx].Hsl()
-
-		S = 0
-		L /= 2
-
-		c := color2.Hsl(H, S, L)
-
-		pl2.HueVariations[trsIdx][palIdx] = uint8(pl2.BasePalette.Index(c))
+	first := 0
+	if group.Tolerance != 0 {
+		first = 1
 	}
 
-	trsIdx++
-
-	// Index 74: Grayscale + Brighten
-	for palIdx := 0; palIdx < 256; palIdx++ {
+	for palIdx := first; palIdx < numPaletteColors; palIdx++ {
 		h, s, l := hslColors[palIdx].Hsl()
 
-		s = 0
-		l += 0.2
-		l /= 1.2
-
-		pl2.HueVariations[trsIdx][palIdx] = uint8(pl2.BasePalette.Index(color2.Hsl(h, s, l)))
-	}
-
-	trsIdx++
-
-	// Index 75 - 98: Tolerance-based hueshift + Grayscale
-	for shiftIdx := 0; shiftIdx < hueSteps; shiftIdx++ {
-		for palIdx := 1; palIdx < 256; palIdx++ {
-			hslColors := pl2.getHSLColors()
-			h, s, l := hslColors[palIdx].Hsl()
-
-			pl2.HueVariations[trsIdx][palIdx] = pl2.HueVariations[trsIdx-1][palIdx]
-
-			const tolerance = 3 * hueRotationPerStep
-
-			if h > tolerance && h < maxDegrees - tolerance {
-				h += float64(shiftIdx) * hueRotationPerStep
+		// the tolerance is measured in HSL, around pure red
+		if group.Tolerance != 0 && (h <= group.Tolerance || h >= maxDegrees-group.Tolerance) {
+			g.pl2.HueVariations[trsIdx][palIdx] = g.pl2.HueVariations[trsIdx-1][palIdx]
+			continue
+		}
 
-				for h > maxDegrees {
-					h -= maxDegrees
-				}
+		if g.opts.HueSpace != "" && g.opts.HueSpace != HueHSL {
+			h, s, l = hueCoordinates(g.opts.HueSpace, hslColors[palIdx])
+		}
 
-				pl2.HueVariations[trsIdx][palIdx] = uint8(pl2.BasePalette.Index(color2.Hsl(h, s, l)))
-			}
+		if group.AbsoluteHue {
+			h = 0
 		}
 
-		trsIdx++
-	}
+		h += float64(shiftIdx) * group.Step
 
-	// Index 99: Full black
-	trsIdx++
+		for h >= maxDegrees {
+			h -= maxDegrees
+		}
 
-	// Index 100 - 111: Full saturation hue shift
-	for shiftIdx := 0; shiftIdx < hueSteps/2; shiftIdx++ {
-		hslColors = pl2.getHSLColors()
-		for palIdx := 0; palIdx < 256; palIdx++ {
-			h, s, l := hslColors[palIdx].Hsl()
+		if group.Saturation != nil {
+			s = *group.Saturation
+		}
 
-			h = float64(shiftIdx) * (hueRotationPerStep * 2)
+		l = math.Min(1, math.Max(0, (l+group.LightnessOffset)/divisor))
 
-			for h > maxDegrees {
-				h -= maxDegrees
-			}
+		t := &g.pl2.HueVariations[trsIdx]
+		t[palIdx] = g.index(t, palIdx, fromHueCoordinates(g.opts.HueSpace, h, s, l))
+	}
+}
 
-			s = 1.0
+func (g *generation) generateRedTones() {
+	g.generateToneTransform(&g.pl2.RedTones, 0)
+}
 
-			pl2.HueVariations[trsIdx][palIdx] = uint8(pl2.BasePalette.Index(color2.Hsl(h, s, l)))
-		}
+func (g *generation) generateGreenTones() {
+	g.generateToneTransform(&g.pl2.GreenTones, 1)
+}
 
-		trsIdx++
-	}
+func (g *generation) generateBlueTones() {
+	g.generateToneTransform(&g.pl2.BlueTones, 2)
 }
 
-func (pl2 *PL2) generateRGBTransforms() {
-	fn := func(r, g, b float64) color.Color {
+// generateToneTransform maps each color to a tone of the red (0), green (1) or blue (2) component.
+func (g *generation) generateToneTransform(dst *Transform, component int) {
+	fn := func(m float64) color.Color {
+		rgb := [3]uint8{}
+		rgb[component] = uint8(m)
+
 		return color.RGBA{
-			R: uint8(r),
-			G: uint8(g),
-			B: uint8(b),
+			R: rgb[0],
+			G: rgb[1],
+			B: rgb[2],
 			A: math.MaxUint8,
 		}
 	}
 
 	// get magnitude for a color, use for Red, Green, and Blue versions
 	for palIdx := 1; palIdx < 256; palIdx++ {
-		base := pl2.BasePalette[palIdx]
+		base := g.pl2.BasePalette[palIdx]
 
-		r, g, b, _ := base.RGBA()
+		r, gr, b, _ := base.RGBA()
 
 		rr := float64(r) * float64(r)
-		gg := float64(g) * float64(g)
+		gg := float64(gr) * float64(gr)
 		bb := float64(b) * float64(b)
 
-		m := math.Sqrt(rr + gg + bb) / math.MaxUint8
+		m := math.Sqrt(rr+gg+bb) / math.MaxUint8
+
+		if g.opts.Mode == ModeVanilla {
+			// the root mean square of the 16 bit components, scaled back to 8 bits, so that
+			// white maps to the brightest tone instead of overflowing
+			const maxComponent, numComponents = 0xFFFF, 3
 
-		pl2.RedTones[palIdx] = uint8(pl2.BasePalette.Index(fn(m, 0, 0)))
-		pl2.GreenTones[palIdx] = uint8(pl2.BasePalette.Index(fn(0, m, 0)))
-		pl2.BlueTones[palIdx] = uint8(pl2.BasePalette.Index(fn(0, 0, m)))
+			m = math.Sqrt((rr+gg+bb)/numComponents) * math.MaxUint8 / maxComponent
+		}
+
+		dst[palIdx] = g.index(dst, palIdx, fn(m))
 	}
 }
 
-func (pl2 *PL2) generateOtherTransforms() {
+func (g *generation) generateUnknownVariations() {
 	// UnknownVarations = 13, offset from 115
-	pl2.UnknownVariations = make([]Transform, unknownVariations)
+	g.pl2.UnknownVariations = make([]Transform, unknownVariations)
 
-	hslColors := pl2.getHSLColors()
+	variations := g.opts.UnknownVariations
+	if variations == nil {
+		variations = []Variation{{Kind: VariationNearBlack}}
+	}
 
 	// Set custom color variations for PD2.
-	for customIdx := range pl2.UnknownVariations {
-		switch customIdx {
-		case 0:
-			// Index 115: Near-full black, rgb(4, 4, 4)
-			for palIdx := 0; palIdx < 256; palIdx++ {
-				H, S, L := hslColors[palIdx].Hsl()
-		
-				S = 0
-				L /= 16
-		
-				c := color2.Hsl(H, S, L + 0.015)
-
-				pl2.UnknownVariations[customIdx][palIdx] = uint8(pl2.BasePalette.Index(c))
-			}
-		default:
+	for customIdx := range g.pl2.UnknownVariations {
+		v := Variation{}
+		if customIdx < len(variations) {
+			v = variations[customIdx]
+		}
+
+		g.generateVariation(&g.pl2.UnknownVariations[customIdx], v)
+	}
+}
+
+// generateVariation generates the transform of a variation into t. Unused variations, and
+// variations of unregistered kinds, are zeroed, or leave colors untouched in vanilla mode.
+func (g *generation) generateVariation(t *Transform, v Variation) {
+	if fn, found := variationFunc(v.Kind); found {
+		if g.ideal == nil {
+			*t = fn(g.pl2.BasePalette, g.matcher, v)
+			return
 		}
+
+		m := &recordingMatcher{Matcher: g.matcher}
+		*t = fn(g.pl2.BasePalette, m, v)
+		g.recordVariation(t, m)
+
+		return
+	}
+
+	if g.opts.Mode == ModeVanilla {
+		*t = Identity()
+		return
 	}
 
-	pl2.generateMaxComponentTransform()
-	pl2.generateDarkenedUnitTransform()
+	*t = Transform{}
 }
 
-func (pl2 *PL2) generateMaxComponentTransform() {
-	pl2.MaxComponentBlend = make([]Transform, maxComponentBlends)
+func (g *generation) generateMaxComponentTransform() {
+	g.pl2.MaxComponentBlend = make([]Transform, maxComponentBlends)
 
 	fnMax := func(r, g, b uint32) uint32 {
 		max := r
@@ -413,10 +380,36 @@ func (pl2 *PL2) generateMaxComponentTransform() {
 		return s + d
 	}
 
-	for dstIdx := 1; dstIdx < numPaletteColors; dstIdx++ {
+	if g.opts.Mode == ModeVanilla {
+		// the 16 bit components overflow the blend above, blend the 8 bit components instead
+		fnApplyMax = func(src, dst, max uint32) uint8 {
+			m := float64(uint8(max)) / math.MaxUint8
+
+			return uint8(float64(uint8(src))*(1-m) + float64(uint8(dst))*m)
+		}
+	}
+
+	// outside of sRGB, colors are interpolated with the weight of the sRGB blend
+	op := blendOp{
+		weight: func(_, dstIdx int) float64 {
+			dr, dg, db, _ := g.pl2.BasePalette[dstIdx].RGBA()
+
+			return 1 - float64(uint8(fnMax(dr, dg, db)))/math.MaxUint8
+		},
+	}
+
+	g.parallel(numPaletteColors-1, func(row int) {
+		dstIdx := row + 1
+
 		for srcIdx := 1; srcIdx < numPaletteColors; srcIdx++ {
-			src := pl2.BasePalette[srcIdx]
-			dst := pl2.BasePalette[dstIdx]
+			if g.blendsInSpace() {
+				t := &g.pl2.MaxComponentBlend[srcIdx]
+				t[dstIdx] = g.index(t, dstIdx, g.blendInSpace(srcIdx, dstIdx, op))
+				continue
+			}
+
+			src := g.pl2.BasePalette[srcIdx]
+			dst := g.pl2.BasePalette[dstIdx]
 
 			sr, sg, sb, _ := src.RGBA()
 			dr, dg, db, _ := dst.RGBA()
@@ -430,30 +423,30 @@ func (pl2 *PL2) generateMaxComponentTransform() {
 				A: math.MaxUint8,
 			}
 
-			pl2.MaxComponentBlend[srcIdx][dstIdx] = uint8(pl2.BasePalette.Index(blended))
+			g.pl2.MaxComponentBlend[srcIdx][dstIdx] = g.index(&g.pl2.MaxComponentBlend[srcIdx], dstIdx, blended)
 		}
-	}
+	})
 }
 
-func (pl2 *PL2) generateDarkenedUnitTransform() {
+func (g *generation) generateDarkenedUnitTransform() {
 	fn := func(n uint32) uint8 {
 		const third = 3
 		return uint8(n / third)
 	}
 
-	for colorIndex := range pl2.DarkenedColorShift {
+	for colorIndex := range g.pl2.DarkenedColorShift {
 		cidx := uint32(colorIndex)
 
-		r, g, b, _ := pl2.BasePalette[cidx].RGBA()
+		r, gr, b, _ := g.pl2.BasePalette[cidx].RGBA()
 
 		// the transform function is applied to each RGB component, per palette entry
 		newColor := color.RGBA{
 			R: fn(r),
-			G: fn(g),
+			G: fn(gr),
 			B: fn(b),
 		}
 
-		pl2.DarkenedColorShift[colorIndex] = uint8(pl2.BasePalette.Index(newColor))
+		g.pl2.DarkenedColorShift[colorIndex] = g.index(&g.pl2.DarkenedColorShift, colorIndex, newColor)
 	}
 }
 
@@ -483,38 +476,78 @@ func defaultTextColors() color.Palette {
 	return p
 }
 
-func (pl2 *PL2) generateTextColorTransforms() {
-	pl2.TextColorShifts = make([]Transform, textShifts)
+func (g *generation) generateTextColorTransforms() {
+	g.pl2.TextColorShifts = make([]Transform, textShifts)
 
 	fn := func(a, b color.Color) color.Color {
 		ar, ag, ab, _ := a.RGBA()
-		br, _, _, _ := b.RGBA()
 
-		intensity := int(br / math.MaxUint8)
+		intensity := int(g.textIntensity(b) / math.MaxUint8)
 
 		return color.RGBA{
-			R: uint8((int(ar / math.MaxUint8) * intensity) / math.MaxUint8),
-			G: uint8((int(ag / math.MaxUint8) * intensity) / math.MaxUint8),
-			B: uint8((int(ab / math.MaxUint8) * intensity) / math.MaxUint8),
+			R: uint8((int(ar/math.MaxUint8) * intensity) / math.MaxUint8),
+			G: uint8((int(ag/math.MaxUint8) * intensity) / math.MaxUint8),
+			B: uint8((int(ab/math.MaxUint8) * intensity) / math.MaxUint8),
 			A: uint8(math.MaxUint8),
 		}
 	}
 
-	for textColorIdx := 1; textColorIdx < len(pl2.TextColorShifts); textColorIdx++ {
-		textColor := pl2.TextColors[textColorIdx]
+	first := 1
+	if g.opts.Mode == ModeVanilla {
+		first = 0
+	}
+
+	for textColorIdx := first; textColorIdx < len(g.pl2.TextColorShifts); textColorIdx++ {
+		textColor := g.pl2.TextColors[textColorIdx]
 
 		for colorIdx := 1; colorIdx < numPaletteColors; colorIdx++ {
-			baseColor := pl2.BasePalette[colorIdx]
+			baseColor := g.pl2.BasePalette[colorIdx]
 			dstColor := fn(textColor, baseColor)
 
-			pl2.TextColorShifts[textColorIdx][colorIdx] = uint8(pl2.BasePalette.Index(dstColor))
+			t := &g.pl2.TextColorShifts[textColorIdx]
+			t[colorIdx] = g.index(t, colorIdx, dstColor)
+		}
+	}
+}
+
+// textIntensity returns the 16 bit component of the base color scaling text colors.
+func (g *generation) textIntensity(c color.Color) uint32 {
+	r, gr, b, _ := c.RGBA()
+
+	switch g.opts.TextIntensity {
+	case TextIntensityLuma:
+		const wr, wg, wb = 0.299, 0.587, 0.114
+
+		return uint32(math.Round(wr*float64(r) + wg*float64(gr) + wb*float64(b)))
+	case TextIntensityMax:
+		max := r
+
+		if gr > max {
+			max = gr
+		}
+
+		if b > max {
+			max = b
 		}
+
+		return max
 	}
+
+	return r
 }
 
 type simpleTransform = func(idx int, component uint8) uint8
 
-func (pl2 *PL2) applyVariations(numTransforms int, fn simpleTransform) []Transform {
+func (g *generation) applyVariations(numTransforms int, fn simpleTransform) []Transform {
+	return g.applyComponentVariations(numTransforms, func(idx, _ int, n uint8) uint8 {
+		return fn(idx, n)
+	})
+}
+
+// componentTransform is a simpleTransform which also gets the index of the RGB component.
+type componentTransform = func(idx, component int, n uint8) uint8
+
+func (g *generation) applyComponentVariations(numTransforms int, fn componentTransform) []Transform {
 	trs := make([]Transform, numTransforms)
 
 	for variationIndex := range trs {
@@ -529,19 +562,19 @@ func (pl2 *PL2) applyVariations(numTransforms int, fn simpleTransform) []Transfo
 				continue
 			}
 
-			r, g, b, _ := pl2.BasePalette[cidx].RGBA()
+			r, gr, b, _ := g.pl2.BasePalette[cidx].RGBA()
 			r8 := uint8(r)
-			g8 := uint8(g)
+			g8 := uint8(gr)
 			b8 := uint8(b)
 
 			// the transform function is applied to each RGB component, per palette entry
 			newColor := color.RGBA{
-				R: fn(vidx, r8),
-				G: fn(vidx, g8),
-				B: fn(vidx, b8),
+				R: fn(vidx, 0, r8),
+				G: fn(vidx, 1, g8),
+				B: fn(vidx, 2, b8),
 			}
 
-			transformIdx := uint8(pl2.BasePalette.Index(newColor))
+			transformIdx := g.index(&trs[variationIndex], colorIndex, newColor)
 			quickLookup[cidx] = &transformIdx
 
 			trs[variationIndex][colorIndex] = transformIdx
@@ -553,9 +586,17 @@ func (pl2 *PL2) applyVariations(numTransforms int, fn simpleTransform) []Transfo
 
 type blendFn func(componentA, componentB uint8) uint8
 
-func (pl2 *PL2) getClosestBlendIndex(src, dst int, fn blendFn) uint8 {
-	sr, sg, sb, _ := pl2.BasePalette[src].RGBA()
-	dr, dg, db, _ := pl2.BasePalette[dst].RGBA()
+// getClosestBlendIndex returns the entry dst of the blend transform t, blending the palette colors
+// src and dst.
+func (g *generation) getClosestBlendIndex(t *Transform, src, dst int, op blendOp) uint8 {
+	if g.blendsInSpace() {
+		return g.index(t, dst, g.blendInSpace(src, dst, op))
+	}
+
+	fn := op.srgb
+
+	sr, sg, sb, _ := g.pl2.BasePalette[src].RGBA()
+	dr, dg, db, _ := g.pl2.BasePalette[dst].RGBA()
 
 	blended := color.RGBA{
 		R: fn(uint8(sr), uint8(dr)),
@@ -564,5 +605,5 @@ func (pl2 *PL2) getClosestBlendIndex(src, dst int, fn blendFn) uint8 {
 		A: math.MaxUint8,
 	}
 
-	return uint8(pl2.BasePalette.Index(blended))
+	return g.index(t, dst, blended)
 }
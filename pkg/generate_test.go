@@ -3,12 +3,13 @@ package pkg
 import (
 	"bytes"
 	"image/color"
+	"math/rand"
 	"testing"
 )
 
-func TestPL2_regenerate(t *testing.T) {
+func TestGenerator_Regenerate(t *testing.T) {
 	type fields struct {
-		BasePalette          color.Palette
+		BasePalette color.Palette
 	}
 
 	tests := []struct {
@@ -20,18 +21,189 @@ func TestPL2_regenerate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			pl2 := &PL2{
-				BasePalette:          tt.fields.BasePalette,
+			src := &PL2{
+				BasePalette: tt.fields.BasePalette,
 			}
 
 			b := bytes.NewBuffer(nil)
 
-			pl2.regenerate()
-
-			err := pl2.Encode(b)
+			pl2, err := NewGenerator(DefaultGenerateOptions()).Regenerate(src)
 			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = pl2.Encode(b); err != nil {
 				t.Error(err)
 			}
 		})
 	}
 }
+
+func TestGenerator_keepsUnselectedSections(t *testing.T) {
+	src, err := FromBytes(randomPL2Bytes(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultGenerateOptions()
+	opts.Sections = []SectionID{SectionHueVariations, SectionGreenTones}
+
+	regenerated, err := NewGenerator(opts).Regenerate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	full, err := NewGenerator(DefaultGenerateOptions()).Regenerate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, section := range Layout() {
+		if !section.ID.IsTransforms() {
+			continue
+		}
+
+		want := src
+		if section.ID == SectionHueVariations || section.ID == SectionGreenTones {
+			want = full
+		}
+
+		for idx, got := range regenerated.Transforms(section.ID) {
+			if *got != *want.Transforms(section.ID)[idx] {
+				t.Fatalf("%s was not taken from the expected PL2", section.ElementName(idx))
+			}
+		}
+	}
+}
+
+func TestGenerator_parallelIsDeterministic(t *testing.T) {
+	p := randomPalette(rand.New(rand.NewSource(4)))
+
+	var serial []byte
+
+	for _, workers := range []int{1, 3, 16} {
+		opts := DefaultGenerateOptions()
+		opts.Workers = workers
+
+		encoded, err := ToBytes(Generate(p, opts))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if serial == nil {
+			serial = encoded
+		}
+
+		if !bytes.Equal(encoded, serial) {
+			t.Errorf("generation with %d workers differs from serial generation", workers)
+		}
+	}
+}
+
+// webSafePalette returns a palette with the transparent black at index 0, the 216 colors of the
+// 6x6x6 RGB cube, and a ramp of grays.
+func webSafePalette() color.Palette {
+	p := color.Palette{color.RGBA{A: 255}}
+
+	for r := 0; r < 6; r++ {
+		for g := 0; g < 6; g++ {
+			for b := 0; b < 6; b++ {
+				p = append(p, color.RGBA{R: uint8(r * 51), G: uint8(g * 51), B: uint8(b * 51), A: 255})
+			}
+		}
+	}
+
+	for len(p) < numPaletteColors {
+		gray := uint8(len(p)-216) * 6
+		p = append(p, color.RGBA{R: gray, G: gray, B: gray, A: 255})
+	}
+
+	return p
+}
+
+func brightness(c color.Color) uint32 {
+	r, g, b, _ := c.RGBA()
+
+	return r + g + b
+}
+
+func TestGenerator_vanillaOrientation(t *testing.T) {
+	p := webSafePalette()
+	pl2 := Generate(p, VanillaGenerateOptions())
+
+	const (
+		black = 1   // first color of the cube
+		white = 216 // last color of the cube
+		red   = 181 // r=5, g=0, b=0
+	)
+
+	// AlphaBlend[level][src][dst] draws src with an opacity of 25%, 50% then 75% over dst
+	for level := 1; level < alphaBlendCoarse; level++ {
+		lighter := p[pl2.AlphaBlend[level][white][black]]
+		darker := p[pl2.AlphaBlend[level-1][white][black]]
+
+		if brightness(lighter) <= brightness(darker) {
+			t.Errorf("AlphaBlend[%d][white][black] is not brighter than AlphaBlend[%d][white][black]", level, level-1)
+		}
+
+		if brightness(p[pl2.AlphaBlend[level][black][white]]) >= brightness(p[pl2.AlphaBlend[level-1][black][white]]) {
+			t.Errorf("AlphaBlend[%d][black][white] is not darker than AlphaBlend[%d][black][white]", level, level-1)
+		}
+	}
+
+	// AdditiveBlend[src][dst] and MultiplicativeBlend[src][dst] are symmetric
+	for src := 1; src < numPaletteColors; src++ {
+		for dst := 1; dst < numPaletteColors; dst++ {
+			if pl2.AdditiveBlend[src][dst] != pl2.AdditiveBlend[dst][src] {
+				t.Fatalf("AdditiveBlend[%d][%d] differs from AdditiveBlend[%d][%d]", src, dst, dst, src)
+			}
+
+			if pl2.MultiplicativeBlend[src][dst] != pl2.MultiplicativeBlend[dst][src] {
+				t.Fatalf("MultiplicativeBlend[%d][%d] differs from MultiplicativeBlend[%d][%d]", src, dst, dst, src)
+			}
+		}
+	}
+
+	if pl2.MultiplicativeBlend[red][white] != red || pl2.MultiplicativeBlend[red][black] != black {
+		t.Errorf("multiplying by white or black does not yield the source color or black")
+	}
+
+	// MaxComponentBlend[src][dst] blends toward dst by its brightest component, so src shows
+	// through a black dst
+	if got := pl2.MaxComponentBlend[red][black]; got != red {
+		t.Errorf("MaxComponentBlend[red][black] is %d, want %d", got, red)
+	}
+
+	if got := pl2.MaxComponentBlend[red][white]; got != white {
+		t.Errorf("MaxComponentBlend[red][white] is %d, want %d", got, white)
+	}
+
+	// every visible color of the full black hue variation is black
+	for palIdx := 1; palIdx < numPaletteColors; palIdx++ {
+		if got := pl2.HueVariations[98][palIdx]; got != black {
+			t.Fatalf("HueVariations[98] maps %d to %d, want black", palIdx, got)
+		}
+	}
+
+	if got := pl2.RedTones[white]; got != red {
+		t.Errorf("RedTones maps white to %d, want the brightest red", got)
+	}
+}
+
+func TestGenerator_vanillaKeepsTransparency(t *testing.T) {
+	pl2 := Generate(webSafePalette(), VanillaGenerateOptions())
+
+	for _, section := range Layout() {
+		for idx, transform := range pl2.Transforms(section.ID) {
+			if transform[0] != 0 {
+				t.Errorf("%s maps the transparent index to %d", section.ElementName(idx), transform[0])
+			}
+
+			for palIdx := 1; palIdx < numPaletteColors; palIdx++ {
+				if transform[palIdx] == 0 {
+					t.Fatalf("%s maps the visible index %d to transparency", section.ElementName(idx), palIdx)
+				}
+			}
+		}
+	}
+}
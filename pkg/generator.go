@@ -0,0 +1,502 @@
+package pkg
+
+import (
+	"fmt"
+	"image/color"
+	"runtime"
+	"sync"
+
+	color2 "github.com/lucasb-eyer/go-colorful"
+)
+
+// GenerationMode selects between variants of the generators.
+type GenerationMode int
+
+// Generation modes
+const (
+	// ModeDefault reproduces the historical output of this package.
+	ModeDefault GenerationMode = iota
+	// ModeVanilla aims at reproducing the tables shipped with the game. Palette index 0 is
+	// transparent, so it maps to itself in every transform, visible colors never map to it, and
+	// blending a transparent source leaves the destination untouched. The full black hue
+	// variation and the first text color shift are generated, unused variations leave colors
+	// untouched, and the tones and max component blend work on 8 bit components instead of
+	// overflowing with 16 bit ones.
+	ModeVanilla
+)
+
+// GenerateOptions are the parameters of transform generation. Start from
+// DefaultGenerateOptions, which reproduces the historical output of EncodePalette.
+type GenerateOptions struct {
+	// Mode selects between variants of the generators.
+	Mode GenerationMode
+	// Metric is used to pick the palette color closest to each computed color.
+	Metric Metric
+	// LightCurve selects how colors are scaled by light levels, LightCurveShift when empty.
+	LightCurve LightCurve
+	// LightLevelShift scales colors of light level n by (n+1) >> LightLevelShift.
+	LightLevelShift uint
+	// LightGamma is the exponent of LightCurveGamma.
+	LightGamma float64
+	// LightPoints are the control points of LightCurvePoints.
+	LightPoints []CurvePoint
+	// LightTint is the hex color of the light, multiplying the lit colors, e.g. a warm orange
+	// for torchlight. Light is white when empty.
+	LightTint string
+	// LightTintStrength blends the light between white and LightTint, 1 when 0.
+	LightTintStrength float64
+	// InvColorCurve selects how colors are faded toward white by inverted color levels,
+	// LightCurveShift when empty.
+	InvColorCurve LightCurve
+	// InvColorShift fades colors of inverted color level n toward white by (n+1) >> InvColorShift.
+	InvColorShift uint
+	// InvColorGamma is the exponent of LightCurveGamma for inverted color levels.
+	InvColorGamma float64
+	// InvColorPoints are the control points of LightCurvePoints for inverted color levels.
+	InvColorPoints []CurvePoint
+	// SelectedUnitLuminosity is added to the HSL lightness of colors for the selected unit shift.
+	SelectedUnitLuminosity float64
+	// AlphaStep is the opacity increment between alpha blend levels.
+	AlphaStep float64
+	// AlphaRatios are the opacities of the alpha blend levels, they replace AlphaStep when set.
+	AlphaRatios []float64
+	// BlendSpace is the color space of the alpha, additive, multiplicative and max component
+	// blends, BlendSRGB when empty.
+	BlendSpace BlendSpace
+	// HueSpace is the color space of the hue variations, HueHSL when empty. Saturations and
+	// lightnesses of hue groups are expressed in it.
+	HueSpace HueSpace
+	// HueStep is the hue rotation between hue variations, in degrees.
+	HueStep float64
+	// HueSaturation is the HSL saturation of the darkened and brightened hue variations.
+	HueSaturation float64
+	// HueDarken is subtracted from the HSL lightness of the darkened hue variations.
+	HueDarken float64
+	// HueBrighten is added to the HSL lightness of the brightened hue variations.
+	HueBrighten float64
+	// HueGroups describe the hue variations, group after group. They replace the hue options
+	// above when set, see DefaultHueGroups.
+	HueGroups []HueGroup
+	// UnknownVariations define the unknown variations, the remaining ones are unused. The
+	// default near black variation is used when nil. Variations of unregistered kinds are
+	// unused, see RegisterVariation.
+	UnknownVariations []Variation
+	// TextIntensity is the base color component scaling the text colors of text color shifts,
+	// TextIntensityRed when empty.
+	TextIntensity TextIntensity
+	// Workers is the number of goroutines generating sections and rows of blend tables, the
+	// number of CPUs when zero. The output does not depend on it.
+	Workers int
+	// ExcludedTargets are palette indices which transforms never map colors to, unless they are
+	// fixed sources. Vanilla mode excludes the transparent index 0.
+	ExcludedTargets []int
+	// FixedSources are palette indices which every transform maps to themselves.
+	FixedSources []int
+	// FixedIndexZero is the index every transform maps the transparent index 0 to when set,
+	// unless 0 is a fixed source. Vanilla mode maps 0 to itself.
+	FixedIndexZero *int
+	// Sections lists the sections to generate, every transform section when empty. When
+	// regenerating a PL2, the other sections are kept from it.
+	Sections []SectionID
+}
+
+// DefaultGenerateOptions returns the options used by EncodePalette.
+func DefaultGenerateOptions() GenerateOptions {
+	return GenerateOptions{
+		Metric:                 MetricRGB,
+		LightLevelShift:        5,
+		InvColorShift:          4,
+		SelectedUnitLuminosity: 0.2,
+		AlphaStep:              0.25,
+		HueStep:                15,
+		HueSaturation:          0.5,
+		HueDarken:              0.1,
+		HueBrighten:            0.2,
+	}
+}
+
+// LightCurve selects how colors are scaled by light levels.
+type LightCurve string
+
+// Light curves
+const (
+	// LightCurveShift scales colors of light level n by (n+1) >> LightLevelShift.
+	LightCurveShift LightCurve = "shift"
+	// LightCurveGamma scales colors of light level n by ((n+1) / 32) ^ LightGamma.
+	LightCurveGamma LightCurve = "gamma"
+	// LightCurveLinear scales colors of light level n by (n+1) / 32, rounding instead of
+	// truncating.
+	LightCurveLinear LightCurve = "linear"
+	// LightCurveLStar scales colors in linear light so that the CIE L* lightness of white grows
+	// linearly with light levels, which looks even to the eye.
+	LightCurveLStar LightCurve = "lstar"
+	// LightCurvePoints interpolates the scales of light levels between control points.
+	LightCurvePoints LightCurve = "points"
+)
+
+// TextIntensity selects the base color component scaling the text colors of text color shifts.
+type TextIntensity string
+
+// Text intensities
+const (
+	// TextIntensityRed is the red component of the base color, the historical intensity.
+	TextIntensityRed TextIntensity = "red"
+	// TextIntensityLuma is the Rec. 601 luma of the base color.
+	TextIntensityLuma TextIntensity = "luma"
+	// TextIntensityMax is the largest component of the base color.
+	TextIntensityMax TextIntensity = "max"
+)
+
+// HueGroup describes a group of consecutive hue variations. The n-th variation of a group,
+// starting at 0, rotates hues by n * Step degrees, or sets them to n * Step degrees when
+// AbsoluteHue is set. Saturation then replaces the HSL saturation when set, and the lightness
+// becomes (l + LightnessOffset) / LightnessDivisor, clamped to [0, 1].
+type HueGroup struct {
+	Count            int      `json:"count"`
+	Step             float64  `json:"step,omitempty"`
+	AbsoluteHue      bool     `json:"absoluteHue,omitempty"`
+	Saturation       *float64 `json:"saturation,omitempty"`
+	LightnessOffset  float64  `json:"lightnessOffset,omitempty"`
+	LightnessDivisor float64  `json:"lightnessDivisor,omitempty"` // 1 when zero
+	// Tolerance limits the rotation to colors whose hue is more than Tolerance degrees away from
+	// red, the other colors are taken from the previous variation. The transparent index is
+	// left untouched.
+	Tolerance float64 `json:"tolerance,omitempty"`
+	// VanillaOnly groups are only generated in vanilla mode, and left zeroed otherwise.
+	VanillaOnly bool `json:"vanillaOnly,omitempty"`
+	// Variation generates every variation of the group instead of the rules above when set,
+	// mods use it for the spare full black slot.
+	Variation *Variation `json:"variation,omitempty"`
+}
+
+// DefaultHueGroups returns the hue groups described by the hue options: hue shifts, darkened
+// and brightened hue shifts, grayscale, brightened grayscale, hue shifts of colors away from
+// red over grayscale, full black, and fully saturated hues.
+func DefaultHueGroups(opts GenerateOptions) []HueGroup {
+	const (
+		gray, saturated                     = 0, 1
+		black                               = -1
+		grayBrighten, grayBrightenedDivisor = 0.2, 1.2
+	)
+
+	return []HueGroup{
+		{Count: hueSteps, Step: opts.HueStep},
+		{Count: hueSteps, Step: opts.HueStep, Saturation: float(opts.HueSaturation), LightnessOffset: -opts.HueDarken},
+		{Count: hueSteps, Step: opts.HueStep, Saturation: float(opts.HueSaturation), LightnessOffset: opts.HueBrighten},
+		{Count: 1, Saturation: float(gray), LightnessDivisor: 2},
+		{Count: 1, Saturation: float(gray), LightnessOffset: grayBrighten, LightnessDivisor: grayBrightenedDivisor},
+		{Count: hueSteps, Step: opts.HueStep, Tolerance: 3 * opts.HueStep},
+		{Count: 1, Saturation: float(gray), LightnessOffset: black, VanillaOnly: true},
+		{Count: hueSteps / 2, Step: 2 * opts.HueStep, AbsoluteHue: true, Saturation: float(saturated)},
+	}
+}
+
+func float(v float64) *float64 {
+	return &v
+}
+
+// VanillaGenerateOptions returns the default options, in vanilla mode.
+func VanillaGenerateOptions() GenerateOptions {
+	opts := DefaultGenerateOptions()
+	opts.Mode = ModeVanilla
+
+	return opts
+}
+
+// Generator generates PL2 transforms from palettes. It keeps no state between generations, and
+// is safe for concurrent use.
+type Generator struct {
+	opts GenerateOptions
+}
+
+// NewGenerator returns a Generator using the given options.
+func NewGenerator(opts GenerateOptions) *Generator {
+	opts.Sections = append([]SectionID(nil), opts.Sections...)
+
+	if opts.Metric == nil {
+		opts.Metric = MetricRGB
+	}
+
+	return &Generator{opts: opts}
+}
+
+// Generate generates a PL2 for the given base palette, with the default text colors. Every
+// transform section is generated, regardless of GenerateOptions.Sections. It returns nil when
+// the options are invalid, use Generator.Generate to get the error.
+func Generate(p color.Palette, opts GenerateOptions) *PL2 {
+	opts.Sections = nil
+
+	pl2, _ := NewGenerator(opts).Generate(p, nil)
+
+	return pl2
+}
+
+// Generate generates a PL2 for the given base palette and text colors. Missing colors are
+// filled in as described by PL2.SetMainPalette and PL2.SetTextPalette.
+func (gen *Generator) Generate(base, text color.Palette) (*PL2, error) {
+	pl2 := &PL2{}
+
+	pl2.SetMainPalette(base)
+	pl2.SetTextPalette(text)
+
+	return gen.generate(pl2, nil)
+}
+
+// Regenerate returns a copy of the given PL2, with the sections selected by the options
+// generated from its palettes. The given PL2 is left untouched.
+func (gen *Generator) Regenerate(src *PL2) (*PL2, error) {
+	pl2 := &PL2{}
+
+	pl2.SetMainPalette(src.BasePalette)
+	pl2.SetTextPalette(src.TextColors)
+
+	return gen.generate(pl2, src)
+}
+
+func (gen *Generator) generate(pl2, src *PL2) (*PL2, error) {
+	pl2, _, err := gen.generateReport(pl2, src, false)
+
+	return pl2, err
+}
+
+// generateReport generates the selected sections, and reports their quantization errors when
+// diagnosing.
+func (gen *Generator) generateReport(pl2, src *PL2, diagnose bool) (*PL2, *QuantizationReport, error) {
+	pl2.allocateTransforms()
+
+	// sections are generated into a scratch PL2, as some generators produce several sections
+	// at once, and only the selected ones are copied over
+	scratch := &PL2{
+		BasePalette: pl2.BasePalette,
+		TextColors:  pl2.TextColors,
+	}
+
+	scratch.allocateTransforms()
+
+	workers := gen.opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	protected, err := newProtection(gen.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if gen.opts.HueGroups != nil {
+		if err := checkHueGroups(gen.opts.HueGroups); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := checkVariations(gen.opts.UnknownVariations); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkCurve("light", gen.opts.LightCurve, gen.opts.LightGamma, gen.opts.LightPoints, lightLevelVariations); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkCurve("inverted color", gen.opts.InvColorCurve, gen.opts.InvColorGamma, gen.opts.InvColorPoints, invColorVariations); err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkLightTint(gen.opts.LightTint, gen.opts.LightTintStrength); err != nil {
+		return nil, nil, err
+	}
+
+	g := &generation{
+		opts:    gen.opts,
+		pl2:     scratch,
+		matcher: gen.opts.Metric.Matcher(pl2.BasePalette),
+		tokens:  make(chan struct{}, workers),
+	}
+
+	if diagnose {
+		g.ideal = make(map[*Transform]*idealTransform)
+	}
+
+	if allowed := protected.allowedTargets(); len(allowed) < numPaletteColors {
+		g.matcher = newSubsetMatcher(gen.opts.Metric, pl2.BasePalette, allowed)
+	}
+
+	selected := gen.selectedSections()
+	wg := &sync.WaitGroup{}
+
+	// sections are independent, each one is generated by its own goroutine
+	for _, section := range layout {
+		if !selected[section.ID] {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(id SectionID) {
+			defer wg.Done()
+
+			g.generateSection(id)
+		}(section.ID)
+	}
+
+	wg.Wait()
+
+	for _, section := range layout {
+		if !selected[section.ID] {
+			continue
+		}
+
+		if gen.opts.Mode == ModeVanilla {
+			keepTransparency(scratch, section.ID)
+		}
+
+		protected.apply(scratch.Transforms(section.ID), scratch.BasePalette, g.matcher)
+	}
+
+	var report *QuantizationReport
+	if diagnose {
+		report = g.quantizationReport(selected)
+	}
+
+	for _, section := range layout {
+		if !section.ID.IsTransforms() {
+			continue
+		}
+
+		from := src
+		if selected[section.ID] {
+			from = scratch
+		}
+
+		if err := copySection(pl2, from, section); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return pl2, report, nil
+}
+
+// keepTransparency makes blends with a transparent source leave the destination untouched.
+func keepTransparency(pl2 *PL2, id SectionID) {
+	transforms := pl2.Transforms(id)
+
+	switch id {
+	case SectionAlphaBlend:
+		for level := 0; level < alphaBlendCoarse; level++ {
+			*transforms[level*alphaBlendFine] = Identity()
+		}
+	case SectionAdditiveBlend, SectionMultiplicativeBlend, SectionMaxComponentBlend:
+		*transforms[0] = Identity()
+	}
+}
+
+func (gen *Generator) selectedSections() map[SectionID]bool {
+	selected := make(map[SectionID]bool)
+
+	for _, id := range gen.opts.Sections {
+		selected[id] = id.IsTransforms()
+	}
+
+	if len(gen.opts.Sections) == 0 {
+		for _, section := range layout {
+			selected[section.ID] = section.ID.IsTransforms()
+		}
+	}
+
+	return selected
+}
+
+// copySection copies the transforms of a section from src into dst.
+func copySection(dst, src *PL2, section Section) error {
+	if src == nil {
+		return fmt.Errorf("cannot keep %s without a PL2 to regenerate", section.Name)
+	}
+
+	transforms := src.Transforms(section.ID)
+	if len(transforms) != section.Count {
+		const fmtErr = "cannot copy %s, has %d transforms, expected %d"
+		return fmt.Errorf(fmtErr, section.Name, len(transforms), section.Count)
+	}
+
+	for idx, t := range dst.Transforms(section.ID) {
+		*t = *transforms[idx]
+	}
+
+	return nil
+}
+
+// generation holds the state of a single generation: the PL2 being generated, and the caches
+// derived from its base palette.
+type generation struct {
+	opts    GenerateOptions
+	pl2     *PL2
+	matcher Matcher
+
+	// tokens bounds the number of goroutines doing actual work, one token per worker
+	tokens chan struct{}
+
+	hslColorsOnce   sync.Once
+	hslColorsBuffer []color2.Color
+
+	blendColorsOnce   sync.Once
+	blendColorsBuffer []blendSpaceColors
+
+	// ideal holds the colors computed for each transform entry when diagnosing, nil otherwise
+	ideal      map[*Transform]*idealTransform
+	idealMutex sync.Mutex
+}
+
+// generateSection generates a section of the scratch PL2. Sections made of rows of blends are
+// generated one row per worker, the others by a single worker.
+func (g *generation) generateSection(id SectionID) {
+	rowGenerators := map[SectionID]func(){
+		SectionAlphaBlend:          g.generateAlphaTransforms,
+		SectionAdditiveBlend:       g.generateAdditiveTransforms,
+		SectionMultiplicativeBlend: g.generateMultiplicativeTransforms,
+		SectionMaxComponentBlend:   g.generateMaxComponentTransform,
+	}
+
+	if generator, found := rowGenerators[id]; found {
+		generator()
+		return
+	}
+
+	generator := map[SectionID]func(){
+		SectionLightLevelVariations: g.generateLightLevelVariations,
+		SectionInvColorVariations:   g.generateInvColorVariations,
+		SectionSelectedUnitShift:    g.generateSelectedUnitTransforms,
+		SectionHueVariations:        g.generateHueTransforms,
+		SectionRedTones:             g.generateRedTones,
+		SectionGreenTones:           g.generateGreenTones,
+		SectionBlueTones:            g.generateBlueTones,
+		SectionUnknownVariations:    g.generateUnknownVariations,
+		SectionDarkenedColorShift:   g.generateDarkenedUnitTransform,
+		SectionTextColorShifts:      g.generateTextColorTransforms,
+	}[id]
+
+	g.parallel(1, func(int) {
+		generator()
+	})
+}
+
+// parallel calls fn for every row in [0, rows), each call holding a worker token. Rows must not
+// depend on each other.
+func (g *generation) parallel(rows int, fn func(row int)) {
+	wg := &sync.WaitGroup{}
+
+	for row := 0; row < rows; row++ {
+		g.tokens <- struct{}{}
+
+		wg.Add(1)
+
+		go func(row int) {
+			defer func() {
+				<-g.tokens
+				wg.Done()
+			}()
+
+			fn(row)
+		}(row)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,244 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SectionID identifies a section of a PL2 file. Sections are numbered in file order.
+type SectionID int
+
+// PL2 file sections, in file order
+const (
+	SectionBasePalette SectionID = iota
+	SectionLightLevelVariations
+	SectionInvColorVariations
+	SectionSelectedUnitShift
+	SectionAlphaBlend
+	SectionAdditiveBlend
+	SectionMultiplicativeBlend
+	SectionHueVariations
+	SectionRedTones
+	SectionGreenTones
+	SectionBlueTones
+	SectionUnknownVariations
+	SectionMaxComponentBlend
+	SectionDarkenedColorShift
+	SectionTextColors
+	SectionTextColorShifts
+	NumSections int = iota
+)
+
+const (
+	basePaletteColorSize = 4 // r, g, b and a padding byte
+	textColorSize        = 3 // r, g, b
+	transformSize        = numPaletteColors
+)
+
+type sectionSpec struct {
+	name        string
+	dims        []int
+	elementSize int
+}
+
+// sectionSpecs is the single source of truth for the file layout, indexed by SectionID.
+// Sections with nil dims hold a single element.
+var sectionSpecs = [NumSections]sectionSpec{
+	SectionBasePalette:          {"BasePalette", []int{numPaletteColors}, basePaletteColorSize},
+	SectionLightLevelVariations: {"LightLevelVariations", []int{lightLevelVariations}, transformSize},
+	SectionInvColorVariations:   {"InvColorVariations", []int{invColorVariations}, transformSize},
+	SectionSelectedUnitShift:    {"SelectedUnitShift", nil, transformSize},
+	SectionAlphaBlend:           {"AlphaBlend", []int{alphaBlendCoarse, alphaBlendFine}, transformSize},
+	SectionAdditiveBlend:        {"AdditiveBlend", []int{additiveBlends}, transformSize},
+	SectionMultiplicativeBlend:  {"MultiplicativeBlend", []int{multiplyBlends}, transformSize},
+	SectionHueVariations:        {"HueVariations", []int{hueVariations}, transformSize},
+	SectionRedTones:             {"RedTones", nil, transformSize},
+	SectionGreenTones:           {"GreenTones", nil, transformSize},
+	SectionBlueTones:            {"BlueTones", nil, transformSize},
+	SectionUnknownVariations:    {"UnknownVariations", []int{unknownVariations}, transformSize},
+	SectionMaxComponentBlend:    {"MaxComponentBlend", []int{maxComponentBlends}, transformSize},
+	SectionDarkenedColorShift:   {"DarkenedColorShift", nil, transformSize},
+	SectionTextColors:           {"TextColors", []int{numTextColors}, textColorSize},
+	SectionTextColorShifts:      {"TextColorShifts", []int{textShifts}, transformSize},
+}
+
+var layout = buildLayout()
+
+// Section describes the location of a section in a PL2 file.
+type Section struct {
+	ID   SectionID
+	Name string
+	// Offset is the offset of the first element of the section in the file, in bytes.
+	Offset int64
+	// Dims is the shape of the section, eg. [3 256] for AlphaBlend. It is nil for sections
+	// holding a single element.
+	Dims []int
+	// Count is the number of elements in the section.
+	Count int
+	// ElementSize is the size of each element, in bytes.
+	ElementSize int
+}
+
+func buildLayout() []Section {
+	sections := make([]Section, NumSections)
+	offset := int64(0)
+
+	for idx, spec := range sectionSpecs {
+		count := 1
+		for _, dim := range spec.dims {
+			count *= dim
+		}
+
+		sections[idx] = Section{
+			ID:          SectionID(idx),
+			Name:        spec.name,
+			Offset:      offset,
+			Dims:        spec.dims,
+			Count:       count,
+			ElementSize: spec.elementSize,
+		}
+
+		offset += int64(count * spec.elementSize)
+	}
+
+	return sections
+}
+
+// Layout returns the sections of a PL2 file, in file order.
+func Layout() []Section {
+	sections := make([]Section, len(layout))
+
+	for idx := range layout {
+		sections[idx] = layout[idx].ID.Section()
+	}
+
+	return sections
+}
+
+// Section returns the layout of the section. Unknown sections have an empty layout, named like
+// SectionID.String.
+func (id SectionID) Section() Section {
+	if id < 0 || int(id) >= NumSections {
+		return Section{ID: id, Name: id.String()}
+	}
+
+	s := layout[id]
+	s.Dims = append([]int(nil), s.Dims...)
+
+	return s
+}
+
+func (id SectionID) String() string {
+	if id < 0 || int(id) >= NumSections {
+		return fmt.Sprintf("SectionID(%d)", int(id))
+	}
+
+	return layout[id].Name
+}
+
+// IsTransforms tells if the section holds transforms, as opposed to colors.
+func (id SectionID) IsTransforms() bool {
+	return id != SectionBasePalette && id != SectionTextColors
+}
+
+// Size returns the size of the section, in bytes.
+func (s Section) Size() int64 {
+	return int64(s.Count * s.ElementSize)
+}
+
+// End returns the offset of the first byte after the section.
+func (s Section) End() int64 {
+	return s.Offset + s.Size()
+}
+
+// ElementOffset returns the file offset of the element at the given flat index.
+func (s Section) ElementOffset(index int) int64 {
+	return s.Offset + int64(index*s.ElementSize)
+}
+
+// Index returns the indices of the element at the given flat index, following the dimensions of
+// the section, eg. [1 37] for the flat index 293 of AlphaBlend. It returns nil for single elements.
+func (s Section) Index(flat int) []int {
+	if len(s.Dims) == 0 {
+		return nil
+	}
+
+	index := make([]int, len(s.Dims))
+
+	for dim := len(s.Dims) - 1; dim >= 0; dim-- {
+		index[dim] = flat % s.Dims[dim]
+		flat /= s.Dims[dim]
+	}
+
+	return index
+}
+
+// FlatIndex returns the flat index of the element with the given indices, the inverse of Index.
+func (s Section) FlatIndex(index ...int) (int, error) {
+	if len(index) != len(s.Dims) {
+		const fmtErr = "%s expects %d indices, got %d"
+		return 0, fmt.Errorf(fmtErr, s.Name, len(s.Dims), len(index))
+	}
+
+	flat := 0
+
+	for dim, idx := range index {
+		if idx < 0 || idx >= s.Dims[dim] {
+			return 0, fmt.Errorf("index %d of %s out of range [0, %d)", idx, s.Name, s.Dims[dim])
+		}
+
+		flat = flat*s.Dims[dim] + idx
+	}
+
+	return flat, nil
+}
+
+// ElementName returns the name of the element at the given flat index, eg. "AlphaBlend[1][37]".
+func (s Section) ElementName(flat int) string {
+	return elementName(s.Name, s.Index(flat))
+}
+
+// ParseElement parses an element name such as "HueVariations[42]", "AlphaBlend[1][37]" or
+// "RedTones", and returns its section and flat index. The file offset of the element is then
+// given by Section.ElementOffset.
+func ParseElement(name string) (Section, int, error) {
+	sectionName := name
+	rest := ""
+
+	if bracket := strings.IndexByte(name, '['); bracket >= 0 {
+		sectionName, rest = name[:bracket], name[bracket:]
+	}
+
+	var index []int
+
+	for rest != "" {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 {
+			return Section{}, 0, fmt.Errorf("malformed element name %q", name)
+		}
+
+		idx, err := strconv.Atoi(rest[1:end])
+		if err != nil {
+			return Section{}, 0, fmt.Errorf("malformed element name %q, %w", name, err)
+		}
+
+		index = append(index, idx)
+		rest = rest[end+1:]
+	}
+
+	for _, s := range layout {
+		if s.Name != sectionName {
+			continue
+		}
+
+		flat, err := s.FlatIndex(index...)
+		if err != nil {
+			return Section{}, 0, err
+		}
+
+		return s.ID.Section(), flat, nil
+	}
+
+	return Section{}, 0, fmt.Errorf("unknown section %q", sectionName)
+}
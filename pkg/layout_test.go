@@ -0,0 +1,105 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLayout(t *testing.T) {
+	sections := Layout()
+
+	if len(sections) != NumSections {
+		t.Fatalf("layout has %d sections, want %d", len(sections), NumSections)
+	}
+
+	offset := int64(0)
+	transforms := 0
+
+	for idx, s := range sections {
+		if s.ID != SectionID(idx) {
+			t.Errorf("section %d has id %d", idx, s.ID)
+		}
+
+		if s.Offset != offset {
+			t.Errorf("%s starts at offset %d, want %d", s.Name, s.Offset, offset)
+		}
+
+		if s.ID.IsTransforms() {
+			transforms += s.Count
+		}
+
+		offset = s.End()
+	}
+
+	if offset != FileSize {
+		t.Errorf("layout ends at offset %d, want %d", offset, FileSize)
+	}
+
+	if transforms != NumTransforms {
+		t.Errorf("layout holds %d transforms, want %d", transforms, NumTransforms)
+	}
+}
+
+func TestParseElement(t *testing.T) {
+	tests := []struct {
+		name    string
+		section SectionID
+		flat    int
+		offset  int64
+		wantErr bool
+	}{
+		{"BasePalette[1]", SectionBasePalette, 1, 4, false},
+		{"LightLevelVariations[0]", SectionLightLevelVariations, 0, 1024, false},
+		{"AlphaBlend[1][37]", SectionAlphaBlend, 293, 1024 + (49+293)*256, false},
+		{"HueVariations[42]", SectionHueVariations, 42, 1024 + (49+768+512+42)*256, false},
+		{"RedTones", SectionRedTones, 0, 1024 + (49+768+512+111)*256, false},
+		{"TextColorShifts[12]", SectionTextColorShifts, 12, FileSize - 256, false},
+		{"AlphaBlend[3][0]", 0, 0, 0, true},
+		{"AlphaBlend[1]", 0, 0, 0, true},
+		{"RedTones[0]", 0, 0, 0, true},
+		{"HueVariations[x]", 0, 0, 0, true},
+		{"Nope[1]", 0, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, flat, err := ParseElement(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error state: %v", err)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if s.ID != tt.section || flat != tt.flat {
+				t.Errorf("got %s %d, want %s %d", s.ID, flat, tt.section, tt.flat)
+			}
+
+			if offset := s.ElementOffset(flat); offset != tt.offset {
+				t.Errorf("got offset %d, want %d", offset, tt.offset)
+			}
+
+			if name := s.ElementName(flat); name != tt.name {
+				t.Errorf("got element name %s, want %s", name, tt.name)
+			}
+		})
+	}
+}
+
+func TestSection_Index(t *testing.T) {
+	s := SectionAlphaBlend.Section()
+
+	for flat := 0; flat < s.Count; flat++ {
+		index := s.Index(flat)
+
+		got, err := s.FlatIndex(index...)
+		if err != nil || got != flat {
+			t.Fatalf("flat index %d yields %v, which maps back to %d (%v)", flat, index, got, err)
+		}
+	}
+
+	if got := s.Index(293); !reflect.DeepEqual(got, []int{1, 37}) {
+		t.Errorf("got index %v, want [1 37]", got)
+	}
+}
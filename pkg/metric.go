@@ -0,0 +1,251 @@
+package pkg
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"sync"
+
+	color2 "github.com/lucasb-eyer/go-colorful"
+)
+
+// Metric is a color distance used by the generator to pick the palette color which is closest
+// to a computed color.
+type Metric interface {
+	// Matcher returns a Matcher which finds the closest color of the palette under the metric.
+	Matcher(p color.Palette) Matcher
+}
+
+// Built-in metrics
+var (
+	// MetricRGB is the squared RGB distance of color.Palette.Index, the metric used by default.
+	MetricRGB Metric = namedMetric{rgbMetric{}, "rgb"}
+	// MetricRedmean is the "redmean" weighted RGB distance, which approximates perceived
+	// differences better than plain RGB at a low cost.
+	MetricRedmean Metric = namedMetric{distanceMetric{distance: redmeanDistance}, "redmean"}
+	// MetricCIE76 is the euclidean distance in CIELAB, ΔE*76.
+	MetricCIE76 Metric = namedMetric{spaceMetric(labCoordinates), "cie76"}
+	// MetricCIEDE2000 is the CIEDE2000 color difference, ΔE*00.
+	MetricCIEDE2000 Metric = namedMetric{distanceMetric{distance: color2.Color.DistanceCIEDE2000, key: cie2000Key}, "ciede2000"}
+	// MetricOKLab is the euclidean distance in the OKLab color space.
+	MetricOKLab Metric = namedMetric{spaceMetric(oklabCoordinates), "oklab"}
+)
+
+// namedMetric is a built-in metric. Metrics holding functions cannot be compared, so built-in
+// metrics are told apart by their name.
+type namedMetric struct {
+	Metric
+	name string
+}
+
+var metricNames = map[string]Metric{
+	"rgb":       MetricRGB,
+	"redmean":   MetricRedmean,
+	"cie76":     MetricCIE76,
+	"ciede2000": MetricCIEDE2000,
+	"oklab":     MetricOKLab,
+}
+
+// MetricByName returns the built-in metric with the given name, one of MetricNames.
+func MetricByName(name string) (Metric, error) {
+	m, found := metricNames[name]
+	if !found {
+		return nil, fmt.Errorf("unknown metric %q, expected one of %v", name, MetricNames())
+	}
+
+	return m, nil
+}
+
+// MetricNames returns the names of the built-in metrics.
+func MetricNames() []string {
+	names := make([]string, 0, len(metricNames))
+
+	for name := range metricNames {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// MetricName returns the name of a built-in metric, and false for other metrics.
+func MetricName(m Metric) (string, bool) {
+	named, isBuiltin := m.(namedMetric)
+
+	return named.name, isBuiltin
+}
+
+type rgbMetric struct{}
+
+func (rgbMetric) Matcher(p color.Palette) Matcher {
+	return NewNearest(p)
+}
+
+// spaceMetric is the euclidean distance between the coordinates of two colors in a color space,
+// which can be searched with a k-d tree.
+type spaceMetric func(c color2.Color) kdPoint
+
+func (m spaceMetric) Matcher(p color.Palette) Matcher {
+	points := make([]kdPoint, len(p))
+
+	for idx := range p {
+		points[idx] = m(toColorful(p[idx]))
+	}
+
+	return &spaceMatcher{
+		coordinates: m,
+		tree:        newKDTree(points, 3, square),
+	}
+}
+
+type spaceMatcher struct {
+	coordinates spaceMetric
+	tree        kdTree
+}
+
+func (m *spaceMatcher) Index(c color.Color) int {
+	return m.tree.nearest(m.coordinates(toColorful(c)))
+}
+
+func square(d float64) float64 {
+	return d * d
+}
+
+func labCoordinates(c color2.Color) kdPoint {
+	l, a, b := c.Lab()
+
+	return kdPoint{l, a, b}
+}
+
+func oklabCoordinates(c color2.Color) kdPoint {
+	l, a, b := oklab(c)
+
+	return kdPoint{l, a, b}
+}
+
+// redmeanDistance is the weighted RGB distance described in https://www.compuphase.com/cmetric.htm
+func redmeanDistance(c1, c2 color2.Color) float64 {
+	const scale = 255
+
+	rmean := (c1.R + c2.R) * scale / 2
+	dr, dg, db := (c1.R-c2.R)*scale, (c1.G-c2.G)*scale, (c1.B-c2.B)*scale
+
+	return (2+rmean/256)*dr*dr + 4*dg*dg + (2+(255-rmean)/256)*db*db
+}
+
+// DistanceMetric returns a Metric for an arbitrary distance function. Its matchers compare a
+// color against every palette color, and remember the closest index of each color they have
+// been asked about.
+func DistanceMetric(distance func(c1, c2 color2.Color) float64) Metric {
+	return distanceMetric{distance: distance}
+}
+
+type distanceMetric struct {
+	distance func(c1, c2 color2.Color) float64
+	// key is optional, the distance between two colors must be at least the absolute difference
+	// of their keys. Matchers use it to skip palette colors which cannot be the closest.
+	key func(c color2.Color) float64
+}
+
+// cie2000Key bounds ΔE*00 by the lightness difference, weighted by the largest lightness
+// compensation S_L of the formula, which is below 1.75 for lightness values in [0, 100].
+func cie2000Key(c color2.Color) float64 {
+	const maxSL = 1.75
+
+	l, _, _ := c.Lab()
+
+	return l / maxSL
+}
+
+func (m distanceMetric) Matcher(p color.Palette) Matcher {
+	sm := &scanMatcher{
+		metric: m,
+		colors: make([]color2.Color, len(p)),
+		keys:   make([]float64, len(p)),
+		order:  make([]int, len(p)),
+	}
+
+	for idx := range p {
+		sm.order[idx] = idx
+		sm.colors[idx] = toColorful(p[idx])
+
+		if m.key != nil {
+			sm.keys[idx] = m.key(sm.colors[idx])
+		}
+	}
+
+	// colors are visited by increasing key, the order of colors with the same key is kept so
+	// that the search can stop as soon as the keys differ too much
+	sort.SliceStable(sm.order, func(i, j int) bool {
+		return sm.keys[sm.order[i]] < sm.keys[sm.order[j]]
+	})
+
+	sortedKeys := make([]float64, len(p))
+	for pos, idx := range sm.order {
+		sortedKeys[pos] = sm.keys[idx]
+	}
+
+	sm.keys = sortedKeys
+
+	return sm
+}
+
+type scanMatcher struct {
+	metric distanceMetric
+	colors []color2.Color
+	keys   []float64 // sorted keys
+	order  []int     // palette indices, in the order of keys
+	memo   sync.Map  // 48 bit RGB key => closest index
+}
+
+func (m *scanMatcher) Index(c color.Color) int {
+	r, g, b, _ := c.RGBA()
+	memoKey := uint64(r)<<32 | uint64(g)<<16 | uint64(b)
+
+	if idx, found := m.memo.Load(memoKey); found {
+		return idx.(int)
+	}
+
+	query := toColorful(c)
+	queryKey := 0.0
+
+	if m.metric.key != nil {
+		queryKey = m.metric.key(query)
+	}
+
+	best := kdCandidate{index: -1}
+
+	// visit reports whether colors further away from the query key may still be closer
+	visit := func(pos int) bool {
+		if best.index >= 0 && math.Abs(m.keys[pos]-queryKey) > best.distance {
+			return false
+		}
+
+		idx := m.order[pos]
+		d := m.metric.distance(query, m.colors[idx])
+
+		if best.index < 0 || d < best.distance || (d == best.distance && idx < best.index) {
+			best.index, best.distance = idx, d
+		}
+
+		return true
+	}
+
+	start := sort.SearchFloat64s(m.keys, queryKey)
+
+	for pos := start; pos < len(m.keys) && visit(pos); pos++ {
+	}
+
+	for pos := start - 1; pos >= 0 && visit(pos); pos-- {
+	}
+
+	if best.index < 0 {
+		best.index = 0
+	}
+
+	m.memo.Store(memoKey, best.index)
+
+	return best.index
+}
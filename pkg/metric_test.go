@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"image/color"
+	"math/rand"
+	"testing"
+
+	color2 "github.com/lucasb-eyer/go-colorful"
+)
+
+func TestMetrics_matchClosestColor(t *testing.T) {
+	distances := map[string]func(c1, c2 color2.Color) float64{
+		"redmean":   redmeanDistance,
+		"cie76":     color2.Color.DistanceCIE76,
+		"ciede2000": color2.Color.DistanceCIEDE2000,
+		"oklab": func(c1, c2 color2.Color) float64 {
+			l1, a1, b1 := oklab(c1)
+			l2, a2, b2 := oklab(c2)
+
+			return square(l1-l2) + square(a1-a2) + square(b1-b2)
+		},
+	}
+
+	r := rand.New(rand.NewSource(0))
+	p := randomPalette(r)
+
+	for name, distance := range distances {
+		metric, err := MetricByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		matcher := metric.Matcher(p)
+
+		for idx := 0; idx < 2000; idx++ {
+			c := color.RGBA{R: uint8(r.Intn(256)), G: uint8(r.Intn(256)), B: uint8(r.Intn(256)), A: 255}
+			got := matcher.Index(c)
+
+			dGot, dWant := distance(toColorful(c), toColorful(p[got])), distance(toColorful(c), toColorful(p[0]))
+			for palIdx := range p {
+				if d := distance(toColorful(c), toColorful(p[palIdx])); d < dWant {
+					dWant = d
+				}
+			}
+
+			if dGot > dWant+1e-9 {
+				t.Fatalf("%s: closest index of %v is %d at distance %v, but distance %v exists", name, c, got, dGot, dWant)
+			}
+		}
+	}
+}
+
+func TestOklab_roundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+
+	for idx := 0; idx < 1000; idx++ {
+		c := color2.Color{R: r.Float64(), G: r.Float64(), B: r.Float64()}
+
+		if back := fromOklab(oklab(c)); !back.AlmostEqualRgb(c) {
+			t.Fatalf("%v converts back to %v", c, back)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package pkg
+
+import (
+	"image/color"
+	"sort"
+)
+
+// Matcher finds the index of the palette color which is closest to the given color.
+// color.Palette is a Matcher, albeit a slow one.
+type Matcher interface {
+	Index(c color.Color) int
+}
+
+// Nearest is a Matcher which searches a k-d tree of the palette colors instead of scanning the
+// whole palette. Its results are exactly those of color.Palette.Index, including which index is
+// picked among duplicate colors.
+type Nearest struct {
+	tree kdTree
+}
+
+// NewNearest builds a Nearest for the given palette.
+func NewNearest(p color.Palette) *Nearest {
+	points := make([]kdPoint, len(p))
+
+	// when all colors share the same alpha, it adds the same amount to every distance and
+	// can be left out of the search
+	dims := 3
+
+	for idx := range p {
+		r, g, b, a := p[idx].RGBA()
+		points[idx] = kdPoint{float64(r), float64(g), float64(b), float64(a)}
+
+		if points[idx][3] != points[0][3] {
+			dims = 4
+		}
+	}
+
+	return &Nearest{tree: newKDTree(points, dims, quarterSquare)}
+}
+
+// Index returns the index of the palette color closest to c.
+func (n *Nearest) Index(c color.Color) int {
+	r, g, b, a := c.RGBA()
+
+	return n.tree.nearest(kdPoint{float64(r), float64(g), float64(b), float64(a)})
+}
+
+// quarterSquare is the per-component distance used by color.Palette.Index, applied to 16 bit
+// color components. The result is an integer, so sums are exact in float64.
+func quarterSquare(d float64) float64 {
+	d *= d
+
+	return float64(uint64(d) >> 2)
+}
+
+// kdPoint is a point of up to four dimensions.
+type kdPoint [4]float64
+
+type kdNode struct {
+	point       kdPoint
+	index       int
+	axis        int
+	left, right int
+}
+
+// kdTree finds the nearest of a set of points, where the distance between two points is the sum
+// of a function of the difference along each axis. That function must be even, and increasing
+// with the absolute difference. Ties are resolved in favor of the lowest point index.
+type kdTree struct {
+	nodes []kdNode
+	root  int
+	dims  int
+	term  func(d float64) float64
+}
+
+func newKDTree(points []kdPoint, dims int, term func(d float64) float64) kdTree {
+	t := kdTree{
+		nodes: make([]kdNode, 0, len(points)),
+		dims:  dims,
+		term:  term,
+	}
+
+	indices := make([]int, len(points))
+	for idx := range indices {
+		indices[idx] = idx
+	}
+
+	t.root = t.build(points, indices, 0)
+
+	return t
+}
+
+func (t *kdTree) build(points []kdPoint, indices []int, depth int) int {
+	if len(indices) == 0 {
+		return -1
+	}
+
+	axis := depth % t.dims
+
+	sort.Slice(indices, func(i, j int) bool {
+		return points[indices[i]][axis] < points[indices[j]][axis]
+	})
+
+	median := len(indices) / 2
+
+	node := len(t.nodes)
+	t.nodes = append(t.nodes, kdNode{
+		point: points[indices[median]],
+		index: indices[median],
+		axis:  axis,
+	})
+
+	left := t.build(points, indices[:median], depth+1)
+	right := t.build(points, indices[median+1:], depth+1)
+
+	t.nodes[node].left, t.nodes[node].right = left, right
+
+	return node
+}
+
+func (t *kdTree) distance(a, b kdPoint) float64 {
+	sum := 0.0
+
+	for axis := 0; axis < t.dims; axis++ {
+		sum += t.term(a[axis] - b[axis])
+	}
+
+	return sum
+}
+
+type kdCandidate struct {
+	index    int
+	distance float64
+}
+
+// nearest returns the index of the point nearest to q, or 0 when the tree is empty.
+func (t *kdTree) nearest(q kdPoint) int {
+	best := kdCandidate{index: -1}
+
+	t.search(t.root, q, &best)
+
+	if best.index < 0 {
+		return 0
+	}
+
+	return best.index
+}
+
+func (t *kdTree) search(n int, q kdPoint, best *kdCandidate) {
+	if n < 0 {
+		return
+	}
+
+	node := &t.nodes[n]
+
+	d := t.distance(node.point, q)
+	if best.index < 0 || d < best.distance || (d == best.distance && node.index < best.index) {
+		best.index, best.distance = node.index, d
+	}
+
+	// points on the far side of the splitting plane are at least as far as the plane itself,
+	// they are visited when they could be closer or tie with a lower index
+	diff := q[node.axis] - node.point[node.axis]
+
+	near, far := node.left, node.right
+	if diff > 0 {
+		near, far = node.right, node.left
+	}
+
+	t.search(near, q, best)
+
+	if t.term(diff) <= best.distance {
+		t.search(far, q, best)
+	}
+}
+
+// subsetMatcher only considers some of the palette colors.
+type subsetMatcher struct {
+	matcher Matcher
+	indices []int // palette index of each color of the subset
+}
+
+// newSubsetMatcher returns a Matcher of the given metric, which only picks the palette colors at
+// the given indices. Indices must be sorted, so that ties are still resolved in favor of the
+// lowest palette index.
+func newSubsetMatcher(m Metric, p color.Palette, indices []int) Matcher {
+	subset := make(color.Palette, len(indices))
+
+	for idx, palIdx := range indices {
+		subset[idx] = p[palIdx]
+	}
+
+	return &subsetMatcher{
+		matcher: m.Matcher(subset),
+		indices: indices,
+	}
+}
+
+func (m *subsetMatcher) Index(c color.Color) int {
+	return m.indices[m.matcher.Index(c)]
+}
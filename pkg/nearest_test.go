@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"image/color"
+	"math/rand"
+	"testing"
+
+	color2 "github.com/lucasb-eyer/go-colorful"
+)
+
+func randomPalette(r *rand.Rand) color.Palette {
+	p := make(color.Palette, numPaletteColors)
+
+	for idx := range p {
+		p[idx] = color.RGBA{
+			R: uint8(r.Intn(256)),
+			G: uint8(r.Intn(256)),
+			B: uint8(r.Intn(256)),
+			A: 255,
+		}
+	}
+
+	// duplicates, as found in the D2 palettes
+	for idx := 0; idx < 32; idx++ {
+		p[r.Intn(numPaletteColors)] = p[r.Intn(numPaletteColors)]
+	}
+
+	return p
+}
+
+func TestNearest_matchesPaletteIndex(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+
+	grayscale := &PL2{}
+	grayscale.SetMainPalette(nil)
+
+	palettes := []color.Palette{grayscale.BasePalette, randomPalette(r), randomPalette(r)}
+
+	// palette colors with varying alpha
+	palettes[2][3] = color.NRGBA{R: 10, G: 200, B: 30, A: 128}
+
+	for _, p := range palettes {
+		nearest := NewNearest(p)
+
+		queries := []color.Color{color.Black, color.White, color.Transparent}
+		queries = append(queries, p...)
+
+		for idx := 0; idx < 5000; idx++ {
+			queries = append(queries,
+				color.RGBA{R: uint8(r.Intn(256)), G: uint8(r.Intn(256)), B: uint8(r.Intn(256))},
+				color.RGBA{R: uint8(r.Intn(256)), G: uint8(r.Intn(256)), B: uint8(r.Intn(256)), A: 255},
+				color2.Hsl(r.Float64()*360, r.Float64(), r.Float64()),
+			)
+		}
+
+		for _, c := range queries {
+			if got, want := nearest.Index(c), p.Index(c); got != want {
+				t.Fatalf("closest index of %v is %d, want %d", c, got, want)
+			}
+		}
+	}
+}
+
+func BenchmarkNearest_Index(b *testing.B) {
+	r := rand.New(rand.NewSource(0))
+	p := randomPalette(r)
+	nearest := NewNearest(p)
+
+	b.ResetTimer()
+
+	for idx := 0; idx < b.N; idx++ {
+		nearest.Index(color.RGBA{R: uint8(idx), G: uint8(idx >> 8), B: uint8(idx >> 16), A: 255})
+	}
+}
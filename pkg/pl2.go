@@ -2,11 +2,10 @@ package pkg
 
 import (
 	"bytes"
+	"encoding"
 	"image/color"
 	"io"
 	"math"
-
-	color2 "github.com/lucasb-eyer/go-colorful"
 )
 
 const (
@@ -25,17 +24,23 @@ const (
 	unknownVariations    = 14
 	maxComponentBlends   = 256
 	textShifts           = 13
-	numOtherTransforms 	 = 5 // selected/darkened/r/g/b tones
-	NumTransforms = 1 + // lightLevelVariations +
+
+	// NumTransforms is the number of transforms in a PL2 file, text color shifts included
+	NumTransforms = lightLevelVariations +
 		invColorVariations +
+		1 + // selected unit shift
 		(alphaBlendCoarse * alphaBlendFine) +
 		additiveBlends +
 		multiplyBlends +
 		hueVariations +
+		3 + // red, green and blue tones
 		unknownVariations +
 		maxComponentBlends +
-		textShifts +
-		numOtherTransforms
+		1 + // darkened color shift
+		textShifts
+
+	// FileSize is the size of a PL2 file, in bytes
+	FileSize = numPaletteColors*basePaletteColorSize + NumTransforms*transformSize + numTextColors*textColorSize
 )
 
 // Transform represents a PL2 palette transform.
@@ -59,27 +64,108 @@ func (t Transform) MakePaletteFromPalette(src color.Palette) color.Palette {
 }
 
 // PL2 represents a base palette, and different categories of "transforms" into that palette.
+// It only holds file data: encoding does not modify it, so a PL2 can be shared between
+// goroutines as long as none of them modifies it. Use Clone to get a copy to modify.
 type PL2 struct {
 	BasePalette color.Palette
+	// BasePalettePadding holds the fourth byte of each base palette color. It is zero in the
+	// files shipped with the game, and only kept so that any file encodes back to the same bytes.
+	BasePalettePadding [numPaletteColors]uint8
 
 	LightLevelVariations []Transform
 	InvColorVariations   []Transform
-	SelectedUnitShift   Transform
-	AlphaBlend          [][]Transform
-	AdditiveBlend       []Transform
-	MultiplicativeBlend []Transform
-	HueVariations       []Transform
-	RedTones            Transform
-	GreenTones          Transform
-	BlueTones           Transform
-	UnknownVariations   []Transform
-	MaxComponentBlend   []Transform
-	DarkenedColorShift  Transform
+	SelectedUnitShift    Transform
+	AlphaBlend           [][]Transform
+	AdditiveBlend        []Transform
+	MultiplicativeBlend  []Transform
+	HueVariations        []Transform
+	RedTones             Transform
+	GreenTones           Transform
+	BlueTones            Transform
+	UnknownVariations    []Transform
+	MaxComponentBlend    []Transform
+	DarkenedColorShift   Transform
 
 	TextColors      color.Palette
 	TextColorShifts []Transform
+}
+
+var (
+	_ io.ReaderFrom              = (*PL2)(nil)
+	_ io.WriterTo                = (*PL2)(nil)
+	_ encoding.BinaryMarshaler   = (*PL2)(nil)
+	_ encoding.BinaryUnmarshaler = (*PL2)(nil)
+)
+
+// Transforms returns pointers to the transforms of the given section, in file order. Sections
+// which have not been allocated yield fewer transforms than their layout count, and color
+// sections yield none.
+func (pl2 *PL2) Transforms(id SectionID) []*Transform {
+	switch id {
+	case SectionLightLevelVariations:
+		return transformPointers(pl2.LightLevelVariations)
+	case SectionInvColorVariations:
+		return transformPointers(pl2.InvColorVariations)
+	case SectionSelectedUnitShift:
+		return []*Transform{&pl2.SelectedUnitShift}
+	case SectionAlphaBlend:
+		all := make([]*Transform, 0, alphaBlendCoarse*alphaBlendFine)
+		for blendIdx := range pl2.AlphaBlend {
+			all = append(all, transformPointers(pl2.AlphaBlend[blendIdx])...)
+		}
+
+		return all
+	case SectionAdditiveBlend:
+		return transformPointers(pl2.AdditiveBlend)
+	case SectionMultiplicativeBlend:
+		return transformPointers(pl2.MultiplicativeBlend)
+	case SectionHueVariations:
+		return transformPointers(pl2.HueVariations)
+	case SectionRedTones:
+		return []*Transform{&pl2.RedTones}
+	case SectionGreenTones:
+		return []*Transform{&pl2.GreenTones}
+	case SectionBlueTones:
+		return []*Transform{&pl2.BlueTones}
+	case SectionUnknownVariations:
+		return transformPointers(pl2.UnknownVariations)
+	case SectionMaxComponentBlend:
+		return transformPointers(pl2.MaxComponentBlend)
+	case SectionDarkenedColorShift:
+		return []*Transform{&pl2.DarkenedColorShift}
+	case SectionTextColorShifts:
+		return transformPointers(pl2.TextColorShifts)
+	}
+
+	return nil
+}
+
+func transformPointers(src []Transform) []*Transform {
+	dst := make([]*Transform, len(src))
+
+	for idx := range src {
+		dst[idx] = &src[idx]
+	}
+
+	return dst
+}
+
+// allocateTransforms makes room for every transform of the file layout, discarding existing transforms.
+func (pl2 *PL2) allocateTransforms() {
+	pl2.LightLevelVariations = make([]Transform, lightLevelVariations)
+	pl2.InvColorVariations = make([]Transform, invColorVariations)
+
+	pl2.AlphaBlend = make([][]Transform, alphaBlendCoarse)
+	for blendIdx := range pl2.AlphaBlend {
+		pl2.AlphaBlend[blendIdx] = make([]Transform, alphaBlendFine)
+	}
 
-	hslColorsBuffer []color2.Color
+	pl2.AdditiveBlend = make([]Transform, additiveBlends)
+	pl2.MultiplicativeBlend = make([]Transform, multiplyBlends)
+	pl2.HueVariations = make([]Transform, hueVariations)
+	pl2.UnknownVariations = make([]Transform, unknownVariations)
+	pl2.MaxComponentBlend = make([]Transform, maxComponentBlends)
+	pl2.TextColorShifts = make([]Transform, textShifts)
 }
 
 // FromBytes reads the bytes into a struct
@@ -87,31 +173,58 @@ func FromBytes(data []byte) (*PL2, error) {
 	return (&PL2{}).Decode(bytes.NewReader(data))
 }
 
+// ToBytes encodes the PL2 as-is. Decoding a file with FromBytes and encoding it again with ToBytes
+// yields the original bytes.
 func ToBytes(pl2 *PL2) ([]byte, error) {
-	return EncodePalette(pl2.BasePalette)
+	b := bytes.NewBuffer(nil)
+	err := pl2.Encode(b)
+
+	return b.Bytes(), err
+}
+
+// RegenerateToBytes discards the transforms of the given PL2, regenerates all of them from its
+// base palette and text colors, and encodes the result. The given PL2 is left untouched.
+func RegenerateToBytes(pl2 *PL2) ([]byte, error) {
+	regenerated, err := NewGenerator(DefaultGenerateOptions()).Regenerate(pl2)
+	if err != nil {
+		return nil, err
+	}
+
+	return ToBytes(regenerated)
 }
 
-// Decode the stream into a PL2
+// Decode the stream into a new PL2
 func Decode(rs io.ReadSeeker) (*PL2, error) {
 	return (&PL2{}).Decode(rs)
 }
 
 // EncodePalette encodes the given palette as a PL2
 func EncodePalette(p color.Palette) ([]byte, error) {
-	pl2 := &PL2{}
+	return EncodePaletteWithMetric(p, MetricRGB)
+}
 
-	pl2.SetMainPalette(p)
-	pl2.regenerate()
+// EncodePaletteWithMetric encodes the given palette as a PL2, with transforms picking the closest
+// palette colors under the given metric.
+func EncodePaletteWithMetric(p color.Palette, m Metric) ([]byte, error) {
+	opts := DefaultGenerateOptions()
+	opts.Metric = m
 
-	b := bytes.NewBuffer(nil)
-	err := pl2.Encode(b)
+	pl2, err := NewGenerator(opts).Generate(p, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	return b.Bytes(), err
+	return ToBytes(pl2)
 }
 
+// SetMainPalette sets the base palette to a copy of src. When src holds less than 256 colors,
+// the missing ones are taken from a grayscale palette.
 func (pl2 *PL2) SetMainPalette(src color.Palette) {
+	pl2.BasePalette = mainPalette(src)
+}
+
+func mainPalette(src color.Palette) color.Palette {
 	dst := make(color.Palette, numPaletteColors)
-	pl2.hslColorsBuffer = nil
 
 	// ensure grayscale palette as default
 	if len(src) < numPaletteColors {
@@ -127,13 +240,80 @@ func (pl2 *PL2) SetMainPalette(src color.Palette) {
 
 	copy(dst, src)
 
-	pl2.BasePalette = dst
+	return dst
 }
 
+// SetTextPalette sets the text colors to a copy of src. Missing colors are taken from the
+// default text colors.
 func (pl2 *PL2) SetTextPalette(src color.Palette) {
+	pl2.TextColors = textPalette(src)
+}
+
+func textPalette(src color.Palette) color.Palette {
 	dst := defaultTextColors()
 
 	copy(dst, src)
 
-	pl2.TextColors = dst
+	return dst
+}
+
+// Clone returns a deep copy of the PL2, which can be modified without affecting the original.
+func (pl2 *PL2) Clone() *PL2 {
+	clone := &PL2{
+		BasePalette:        clonePalette(pl2.BasePalette),
+		BasePalettePadding: pl2.BasePalettePadding,
+		TextColors:         clonePalette(pl2.TextColors),
+
+		LightLevelVariations: cloneTransforms(pl2.LightLevelVariations),
+		InvColorVariations:   cloneTransforms(pl2.InvColorVariations),
+		SelectedUnitShift:    pl2.SelectedUnitShift,
+		AdditiveBlend:        cloneTransforms(pl2.AdditiveBlend),
+		MultiplicativeBlend:  cloneTransforms(pl2.MultiplicativeBlend),
+		HueVariations:        cloneTransforms(pl2.HueVariations),
+		RedTones:             pl2.RedTones,
+		GreenTones:           pl2.GreenTones,
+		BlueTones:            pl2.BlueTones,
+		UnknownVariations:    cloneTransforms(pl2.UnknownVariations),
+		MaxComponentBlend:    cloneTransforms(pl2.MaxComponentBlend),
+		DarkenedColorShift:   pl2.DarkenedColorShift,
+		TextColorShifts:      cloneTransforms(pl2.TextColorShifts),
+	}
+
+	if pl2.AlphaBlend != nil {
+		clone.AlphaBlend = make([][]Transform, len(pl2.AlphaBlend))
+
+		for blendIdx := range pl2.AlphaBlend {
+			clone.AlphaBlend[blendIdx] = cloneTransforms(pl2.AlphaBlend[blendIdx])
+		}
+	}
+
+	return clone
+}
+
+func cloneTransforms(src []Transform) []Transform {
+	if src == nil {
+		return nil
+	}
+
+	return append(make([]Transform, 0, len(src)), src...)
+}
+
+func clonePalette(src color.Palette) color.Palette {
+	if src == nil {
+		return nil
+	}
+
+	dst := make(color.Palette, len(src))
+
+	for idx, c := range src {
+		// decoded colors are pointers, other colors are expected to be values
+		if rgba, isPointer := c.(*color.RGBA); isPointer {
+			copied := *rgba
+			c = &copied
+		}
+
+		dst[idx] = c
+	}
+
+	return dst
 }
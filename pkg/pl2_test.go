@@ -0,0 +1,155 @@
+package pkg
+
+import (
+	"bytes"
+	"image/color"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// randomPL2Bytes returns a well-formed PL2 file with random colors and transforms.
+func randomPL2Bytes(seed int64) []byte {
+	const (
+		basePaletteSize   = numPaletteColors * 4
+		numFileTransforms = 1727
+		fileSize          = basePaletteSize + numFileTransforms*numPaletteColors + numTextColors*3
+	)
+
+	data := make([]byte, fileSize)
+
+	rand.New(rand.NewSource(seed)).Read(data)
+
+	return data
+}
+
+func TestToBytes_roundTrip(t *testing.T) {
+	for seed := int64(0); seed < 4; seed++ {
+		data := randomPL2Bytes(seed)
+
+		pl2, err := FromBytes(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encoded, err := ToBytes(pl2)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(data, encoded) {
+			t.Errorf("seed %d: encoded PL2 differs from the decoded bytes", seed)
+		}
+	}
+}
+
+type countingWriter struct {
+	writes int
+	bytes.Buffer
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.writes++
+
+	return w.Buffer.Write(p)
+}
+
+func TestPL2_WriteTo(t *testing.T) {
+	data := randomPL2Bytes(2)
+
+	pl2 := &PL2{}
+	if err := pl2.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &countingWriter{}
+
+	n, err := pl2.WriteTo(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != FileSize || !bytes.Equal(w.Bytes(), data) {
+		t.Errorf("wrote %d bytes which differ from the decoded bytes", n)
+	}
+
+	if w.writes != NumSections {
+		t.Errorf("wrote in %d calls, want one call per section", w.writes)
+	}
+
+	marshaled, err := pl2.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(marshaled, data) {
+		t.Errorf("marshaled PL2 differs from the unmarshaled bytes")
+	}
+}
+
+// run with -race to check that encoding does not modify the PL2
+func TestPL2_concurrentEncode(t *testing.T) {
+	data := randomPL2Bytes(5)
+
+	pl2, err := FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a PL2 without palettes is encoded with the default ones
+	empty := pl2.Clone()
+	empty.BasePalette, empty.TextColors = nil, nil
+
+	wg := &sync.WaitGroup{}
+
+	for idx := 0; idx < 8; idx++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			if encoded, err := ToBytes(pl2); err != nil || !bytes.Equal(encoded, data) {
+				t.Errorf("concurrent encoding differs from the decoded bytes, %v", err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := ToBytes(empty); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if empty.BasePalette != nil || empty.TextColors != nil {
+		t.Errorf("encoding set the default palettes of the PL2")
+	}
+}
+
+func TestPL2_Clone(t *testing.T) {
+	data := randomPL2Bytes(6)
+
+	pl2, err := FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clone := pl2.Clone()
+
+	clone.AlphaBlend[1][37][5]++
+	clone.HueVariations[42][0]++
+	clone.RedTones[1]++
+	clone.BasePalette[3].(*color.RGBA).R++
+
+	encoded, err := ToBytes(pl2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(encoded, data) {
+		t.Errorf("modifying the clone modified the original PL2")
+	}
+}
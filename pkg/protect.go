@@ -0,0 +1,99 @@
+package pkg
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// protection holds the palette indices protected by the generation options.
+type protection struct {
+	excluded [numPaletteColors]bool
+	fixed    [numPaletteColors]bool
+	zero     int // target of index 0, -1 when free
+}
+
+func newProtection(opts GenerateOptions) (*protection, error) {
+	p := &protection{zero: -1}
+
+	if opts.Mode == ModeVanilla {
+		p.excluded[0] = true
+		p.zero = 0
+	}
+
+	for _, idx := range opts.ExcludedTargets {
+		if err := checkIndex("excluded target", idx); err != nil {
+			return nil, err
+		}
+
+		p.excluded[idx] = true
+	}
+
+	for _, idx := range opts.FixedSources {
+		if err := checkIndex("fixed source", idx); err != nil {
+			return nil, err
+		}
+
+		p.fixed[idx] = true
+	}
+
+	if opts.FixedIndexZero != nil {
+		if err := checkIndex("fixed index zero target", *opts.FixedIndexZero); err != nil {
+			return nil, err
+		}
+
+		p.zero = *opts.FixedIndexZero
+	}
+
+	if len(p.allowedTargets()) == 0 {
+		return nil, fmt.Errorf("every palette index is an excluded target")
+	}
+
+	return p, nil
+}
+
+func checkIndex(name string, idx int) error {
+	if idx < 0 || idx >= numPaletteColors {
+		return fmt.Errorf("%s %d is not a palette index", name, idx)
+	}
+
+	return nil
+}
+
+// allowedTargets returns the sorted indices which colors can be mapped to.
+func (p *protection) allowedTargets() []int {
+	allowed := make([]int, 0, numPaletteColors)
+
+	for idx, excluded := range p.excluded {
+		if !excluded {
+			allowed = append(allowed, idx)
+		}
+	}
+
+	return allowed
+}
+
+// apply enforces the protected indices on generated transforms. Entries still mapping to an
+// excluded target, which were not picked by the matcher, are mapped to the allowed color closest
+// to the excluded one.
+func (p *protection) apply(transforms []*Transform, base color.Palette, matcher Matcher) {
+	replacements := make(map[uint8]uint8)
+
+	for _, t := range transforms {
+		for src, dst := range t {
+			switch {
+			case p.fixed[src]:
+				t[src] = uint8(src)
+			case src == 0 && p.zero >= 0:
+				t[src] = uint8(p.zero)
+			case p.excluded[dst]:
+				replacement, found := replacements[dst]
+				if !found {
+					replacement = uint8(matcher.Index(base[dst]))
+					replacements[dst] = replacement
+				}
+
+				t[src] = replacement
+			}
+		}
+	}
+}
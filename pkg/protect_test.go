@@ -0,0 +1,83 @@
+package pkg
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestGenerator_protectedIndices(t *testing.T) {
+	zero := 0
+
+	opts := DefaultGenerateOptions()
+	opts.ExcludedTargets = []int{0, 250, 251, 252, 253, 254, 255}
+	opts.FixedSources = []int{240, 241, 242, 250}
+	opts.FixedIndexZero = &zero
+
+	excluded := make(map[int]bool)
+	for _, idx := range opts.ExcludedTargets {
+		excluded[idx] = true
+	}
+
+	fixed := make(map[int]bool)
+	for _, idx := range opts.FixedSources {
+		fixed[idx] = true
+	}
+
+	pl2, err := NewGenerator(opts).Generate(randomPalette(rand.New(rand.NewSource(11))), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, section := range Layout() {
+		for flat, transform := range pl2.Transforms(section.ID) {
+			for src, dst := range transform {
+				name := section.ElementName(flat)
+
+				switch {
+				case src == 0 && dst != 0:
+					t.Fatalf("%s maps the transparent index to %d", name, dst)
+				case fixed[src] && int(dst) != src:
+					t.Fatalf("%s maps the fixed source %d to %d", name, src, dst)
+				case src != 0 && dst == 0:
+					t.Fatalf("%s maps the visible index %d to transparency", name, src)
+				case !fixed[src] && excluded[int(dst)] && src != 0:
+					t.Fatalf("%s maps %d to the excluded target %d", name, src, dst)
+				}
+			}
+		}
+	}
+}
+
+func TestGenerator_invalidProtectedIndices(t *testing.T) {
+	all := make([]int, numPaletteColors)
+	for idx := range all {
+		all[idx] = idx
+	}
+
+	outOfRange := numPaletteColors
+
+	tests := []struct {
+		name string
+		opts func(opts *GenerateOptions)
+	}{
+		{"excluded target", func(opts *GenerateOptions) { opts.ExcludedTargets = []int{-1} }},
+		{"fixed source", func(opts *GenerateOptions) { opts.FixedSources = []int{outOfRange} }},
+		{"index zero", func(opts *GenerateOptions) { opts.FixedIndexZero = &outOfRange }},
+		{"every target", func(opts *GenerateOptions) { opts.ExcludedTargets = all }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultGenerateOptions()
+			tt.opts(&opts)
+
+			if _, err := NewGenerator(opts).Generate(nil, nil); err == nil {
+				t.Error("expected an error")
+			}
+
+			if pl2 := Generate(nil, opts); pl2 != nil {
+				t.Error("expected no PL2")
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Recipe is a JSON document describing how each section of a PL2 is generated. Recipes are
+// turned into generation options with Recipe.Options, DefaultRecipe reproduces
+// DefaultGenerateOptions.
+type Recipe struct {
+	// Mode is "default" or "vanilla", see GenerationMode.
+	Mode string `json:"mode"`
+	// Metric is the name of a built-in metric, see MetricNames.
+	Metric string `json:"metric"`
+	// BlendSpace is the color space of every blend section.
+	BlendSpace BlendSpace `json:"blendSpace"`
+	// Indices protects palette indices in every section.
+	Indices IndexRecipe `json:"indices"`
+
+	LightLevels  LightLevelRecipe   `json:"lightLevels"`
+	InvColors    InvColorRecipe     `json:"invColors"`
+	SelectedUnit SelectedUnitRecipe `json:"selectedUnit"`
+	AlphaBlend   AlphaBlendRecipe   `json:"alphaBlend"`
+	// HueSpace is the color space of the hue variations.
+	HueSpace HueSpace `json:"hueSpace"`
+	// HueVariations are the groups of hue variations, in order. They must describe 111
+	// variations. The default groups are used when missing.
+	HueVariations []HueGroup `json:"hueVariations"`
+	// UnknownVariations define the unknown variations, the remaining ones are unused. The
+	// default variations are used when missing.
+	UnknownVariations []Variation     `json:"unknownVariations"`
+	TextShifts        TextShiftRecipe `json:"textShifts"`
+}
+
+// IndexRecipe describes the protected palette indices, see GenerateOptions.ExcludedTargets,
+// GenerateOptions.FixedSources and GenerateOptions.FixedIndexZero.
+type IndexRecipe struct {
+	ExcludedTargets []int `json:"excludedTargets,omitempty"`
+	FixedSources    []int `json:"fixedSources,omitempty"`
+	FixedIndexZero  *int  `json:"fixedIndexZero,omitempty"`
+}
+
+// LightLevelRecipe describes the light level variations.
+type LightLevelRecipe struct {
+	Curve        LightCurve   `json:"curve"`
+	Shift        uint         `json:"shift"`
+	Gamma        float64      `json:"gamma,omitempty"`
+	Points       []CurvePoint `json:"points,omitempty"`
+	Tint         string       `json:"tint,omitempty"`
+	TintStrength float64      `json:"tintStrength,omitempty"`
+}
+
+// InvColorRecipe describes the inverted color variations.
+type InvColorRecipe struct {
+	Curve  LightCurve   `json:"curve"`
+	Shift  uint         `json:"shift"`
+	Gamma  float64      `json:"gamma,omitempty"`
+	Points []CurvePoint `json:"points,omitempty"`
+}
+
+// SelectedUnitRecipe describes the selected unit shift.
+type SelectedUnitRecipe struct {
+	Luminosity float64 `json:"luminosity"`
+}
+
+// AlphaBlendRecipe describes the alpha blends, with the opacity of each of the 3 blend levels.
+type AlphaBlendRecipe struct {
+	Ratios []float64 `json:"ratios"`
+}
+
+// TextShiftRecipe describes the text color shifts.
+type TextShiftRecipe struct {
+	Intensity TextIntensity `json:"intensity"`
+}
+
+var modeNames = map[GenerationMode]string{
+	ModeDefault: "default",
+	ModeVanilla: "vanilla",
+}
+
+// DefaultRecipe returns the recipe reproducing DefaultGenerateOptions.
+func DefaultRecipe() *Recipe {
+	r, _ := RecipeFromOptions(DefaultGenerateOptions())
+
+	return r
+}
+
+// RecipeFromOptions returns the recipe describing the given options. Options using a metric
+// which is not built-in cannot be described.
+func RecipeFromOptions(opts GenerateOptions) (*Recipe, error) {
+	if opts.Metric == nil {
+		opts.Metric = MetricRGB
+	}
+
+	metric, isBuiltin := MetricName(opts.Metric)
+	if !isBuiltin {
+		return nil, fmt.Errorf("could not describe options, the metric is not built-in")
+	}
+
+	mode, found := modeNames[opts.Mode]
+	if !found {
+		return nil, fmt.Errorf("could not describe options, unknown mode %d", opts.Mode)
+	}
+
+	r := &Recipe{
+		Mode:       mode,
+		Metric:     metric,
+		BlendSpace: opts.BlendSpace,
+		Indices: IndexRecipe{
+			ExcludedTargets: append([]int(nil), opts.ExcludedTargets...),
+			FixedSources:    append([]int(nil), opts.FixedSources...),
+			FixedIndexZero:  opts.FixedIndexZero,
+		},
+		LightLevels: LightLevelRecipe{
+			Curve:        opts.LightCurve,
+			Shift:        opts.LightLevelShift,
+			Gamma:        opts.LightGamma,
+			Points:       append([]CurvePoint(nil), opts.LightPoints...),
+			Tint:         opts.LightTint,
+			TintStrength: opts.LightTintStrength,
+		},
+		InvColors: InvColorRecipe{
+			Curve:  opts.InvColorCurve,
+			Shift:  opts.InvColorShift,
+			Gamma:  opts.InvColorGamma,
+			Points: append([]CurvePoint(nil), opts.InvColorPoints...),
+		},
+		SelectedUnit:      SelectedUnitRecipe{Luminosity: opts.SelectedUnitLuminosity},
+		AlphaBlend:        AlphaBlendRecipe{Ratios: make([]float64, alphaBlendCoarse)},
+		HueSpace:          opts.HueSpace,
+		HueVariations:     append([]HueGroup(nil), opts.HueGroups...),
+		UnknownVariations: append([]Variation(nil), opts.UnknownVariations...),
+		TextShifts:        TextShiftRecipe{Intensity: opts.TextIntensity},
+	}
+
+	if r.LightLevels.Curve == "" {
+		r.LightLevels.Curve = LightCurveShift
+	}
+
+	if r.InvColors.Curve == "" {
+		r.InvColors.Curve = LightCurveShift
+	}
+
+	if r.TextShifts.Intensity == "" {
+		r.TextShifts.Intensity = TextIntensityRed
+	}
+
+	if r.BlendSpace == "" {
+		r.BlendSpace = BlendSRGB
+	}
+
+	if r.HueSpace == "" {
+		r.HueSpace = HueHSL
+	}
+
+	g := &generation{opts: opts}
+	for level := range r.AlphaBlend.Ratios {
+		r.AlphaBlend.Ratios[level] = g.getBlendRatio(level)
+	}
+
+	if opts.HueGroups == nil {
+		r.HueVariations = DefaultHueGroups(opts)
+	}
+
+	if opts.UnknownVariations == nil {
+		r.UnknownVariations = []Variation{{Kind: VariationNearBlack}}
+	}
+
+	return r, nil
+}
+
+// DecodeRecipe reads a JSON recipe. Fields missing from the document keep the values of the
+// default recipe, unknown fields are errors.
+func DecodeRecipe(rd io.Reader) (*Recipe, error) {
+	r := DefaultRecipe()
+
+	// lists are replaced as a whole, their elements must not inherit default values
+	r.AlphaBlend.Ratios, r.HueVariations, r.UnknownVariations = nil, nil, nil
+	r.Indices = IndexRecipe{}
+
+	decoder := json.NewDecoder(rd)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(r); err != nil {
+		return nil, fmt.Errorf("could not decode recipe, %w", err)
+	}
+
+	defaults := DefaultRecipe()
+
+	if r.AlphaBlend.Ratios == nil {
+		r.AlphaBlend.Ratios = defaults.AlphaBlend.Ratios
+	}
+
+	if r.HueVariations == nil {
+		r.HueVariations = defaults.HueVariations
+	}
+
+	if r.UnknownVariations == nil {
+		r.UnknownVariations = defaults.UnknownVariations
+	}
+
+	return r, nil
+}
+
+// Encode writes the recipe as indented JSON.
+func (r *Recipe) Encode(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("could not encode recipe, %w", err)
+	}
+
+	return nil
+}
+
+// Options returns the generation options described by the recipe, starting from
+// DefaultGenerateOptions.
+func (r *Recipe) Options() (GenerateOptions, error) {
+	opts := DefaultGenerateOptions()
+
+	mode, err := parseMode(r.Mode)
+	if err != nil {
+		return opts, err
+	}
+
+	metric, err := MetricByName(r.Metric)
+	if err != nil {
+		return opts, err
+	}
+
+	if err := checkCurve("light", r.LightLevels.Curve, r.LightLevels.Gamma, r.LightLevels.Points, lightLevelVariations); err != nil {
+		return opts, err
+	}
+
+	if err := checkCurve("inverted color", r.InvColors.Curve, r.InvColors.Gamma, r.InvColors.Points, invColorVariations); err != nil {
+		return opts, err
+	}
+
+	if err := checkLightTint(r.LightLevels.Tint, r.LightLevels.TintStrength); err != nil {
+		return opts, err
+	}
+
+	switch r.BlendSpace {
+	case BlendSRGB, BlendLinear, BlendOKLab:
+	default:
+		const fmtErr = "unknown blend space %q, expected %q, %q or %q"
+		return opts, fmt.Errorf(fmtErr, r.BlendSpace, BlendSRGB, BlendLinear, BlendOKLab)
+	}
+
+	switch r.HueSpace {
+	case HueHSL, HueOKLCH, HueHSLuv:
+	default:
+		const fmtErr = "unknown hue space %q, expected %q, %q or %q"
+		return opts, fmt.Errorf(fmtErr, r.HueSpace, HueHSL, HueOKLCH, HueHSLuv)
+	}
+
+	switch r.TextShifts.Intensity {
+	case TextIntensityRed, TextIntensityLuma, TextIntensityMax:
+	default:
+		const fmtErr = "unknown text intensity %q, expected %q, %q or %q"
+		return opts, fmt.Errorf(fmtErr, r.TextShifts.Intensity, TextIntensityRed, TextIntensityLuma, TextIntensityMax)
+	}
+
+	if len(r.AlphaBlend.Ratios) != alphaBlendCoarse {
+		const fmtErr = "recipe has %d alpha blend ratios, expected %d"
+		return opts, fmt.Errorf(fmtErr, len(r.AlphaBlend.Ratios), alphaBlendCoarse)
+	}
+
+	if _, err := newProtection(GenerateOptions{
+		ExcludedTargets: r.Indices.ExcludedTargets,
+		FixedSources:    r.Indices.FixedSources,
+		FixedIndexZero:  r.Indices.FixedIndexZero,
+	}); err != nil {
+		return opts, err
+	}
+
+	if err := checkHueGroups(r.HueVariations); err != nil {
+		return opts, err
+	}
+
+	if err := checkVariations(r.UnknownVariations); err != nil {
+		return opts, err
+	}
+
+	opts.Mode = mode
+	opts.Metric = metric
+	opts.ExcludedTargets = append([]int(nil), r.Indices.ExcludedTargets...)
+	opts.FixedSources = append([]int(nil), r.Indices.FixedSources...)
+	opts.FixedIndexZero = r.Indices.FixedIndexZero
+	opts.BlendSpace = r.BlendSpace
+	opts.HueSpace = r.HueSpace
+	opts.LightCurve = r.LightLevels.Curve
+	opts.LightLevelShift = r.LightLevels.Shift
+	opts.LightGamma = r.LightLevels.Gamma
+	opts.LightPoints = append([]CurvePoint(nil), r.LightLevels.Points...)
+	opts.LightTint = r.LightLevels.Tint
+	opts.LightTintStrength = r.LightLevels.TintStrength
+	opts.InvColorCurve = r.InvColors.Curve
+	opts.InvColorShift = r.InvColors.Shift
+	opts.InvColorGamma = r.InvColors.Gamma
+	opts.InvColorPoints = append([]CurvePoint(nil), r.InvColors.Points...)
+	opts.SelectedUnitLuminosity = r.SelectedUnit.Luminosity
+	opts.AlphaRatios = append([]float64(nil), r.AlphaBlend.Ratios...)
+	opts.HueGroups = append([]HueGroup(nil), r.HueVariations...)
+	opts.UnknownVariations = append([]Variation{}, r.UnknownVariations...)
+	opts.TextIntensity = r.TextShifts.Intensity
+
+	return opts, nil
+}
+
+func parseMode(name string) (GenerationMode, error) {
+	for mode, modeName := range modeNames {
+		if modeName == name {
+			return mode, nil
+		}
+	}
+
+	return ModeDefault, fmt.Errorf("unknown mode %q, expected %q or %q", name, modeNames[ModeDefault], modeNames[ModeVanilla])
+}
+
+func checkCurve(name string, curve LightCurve, gamma float64, points []CurvePoint, count int) error {
+	switch curve {
+	case "", LightCurveShift, LightCurveLinear, LightCurveLStar:
+	case LightCurveGamma:
+		if gamma <= 0 {
+			return fmt.Errorf("%s gamma %v is not positive", name, gamma)
+		}
+	case LightCurvePoints:
+		return checkCurvePoints(name, points, count)
+	default:
+		const fmtErr = "unknown %s curve %q, expected %q, %q, %q, %q or %q"
+		return fmt.Errorf(fmtErr, name, curve, LightCurveShift, LightCurveGamma, LightCurveLinear, LightCurveLStar, LightCurvePoints)
+	}
+
+	return nil
+}
+
+func checkHueGroups(groups []HueGroup) error {
+	count := 0
+
+	for idx, group := range groups {
+		if group.Count < 0 {
+			return fmt.Errorf("hue group %d has a negative count", idx)
+		}
+
+		if group.Tolerance != 0 && count == 0 {
+			return fmt.Errorf("hue group %d has a tolerance, but no previous variation", idx)
+		}
+
+		if group.Variation != nil {
+			if err := group.Variation.check(); err != nil {
+				return fmt.Errorf("invalid hue group %d, %w", idx, err)
+			}
+		}
+
+		count += group.Count
+	}
+
+	if count != hueVariations {
+		return fmt.Errorf("hue groups describe %d variations, expected %d", count, hueVariations)
+	}
+
+	return nil
+}
+
+func checkVariations(variations []Variation) error {
+	if len(variations) > unknownVariations {
+		const fmtErr = "%d unknown variations, expected at most %d"
+		return fmt.Errorf(fmtErr, len(variations), unknownVariations)
+	}
+
+	for idx, v := range variations {
+		if err := v.check(); err != nil {
+			return fmt.Errorf("invalid unknown variation %d, %w", idx, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package pkg
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestDefaultRecipe(t *testing.T) {
+	b := bytes.NewBuffer(nil)
+
+	if err := DefaultRecipe().Encode(b); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded := b.String()
+
+	decoded, err := DecodeRecipe(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := decoded.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := RecipeFromOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Reset()
+
+	if err := r.Encode(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.String() != encoded {
+		t.Errorf("recipe changed after a round trip, got\n%s\nwant\n%s", b.String(), encoded)
+	}
+
+	// the sections built from recipe rules, the others use the same options as before
+	sections := []SectionID{
+		SectionLightLevelVariations,
+		SectionHueVariations,
+		SectionUnknownVariations,
+		SectionTextColorShifts,
+	}
+
+	src := &PL2{BasePalette: randomPalette(rand.New(rand.NewSource(3)))}
+	src.allocateTransforms()
+
+	want := DefaultGenerateOptions()
+	want.Sections, opts.Sections = sections, sections
+
+	got, err := NewGenerator(opts).Regenerate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := NewGenerator(want).Regenerate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range sections {
+		gotTransforms, wantTransforms := got.Transforms(id), expected.Transforms(id)
+
+		for idx := range wantTransforms {
+			if *gotTransforms[idx] != *wantTransforms[idx] {
+				t.Errorf("%s differs from the default options", id.Section().ElementName(idx))
+			}
+		}
+	}
+}
+
+func TestRecipe_Options(t *testing.T) {
+	tests := []struct {
+		name    string
+		recipe  string
+		wantErr string
+	}{
+		{"empty", `{}`, ""},
+		{"vanilla gamma", `{"mode": "vanilla", "metric": "oklab", "lightLevels": {"curve": "gamma", "gamma": 2.2}}`, ""},
+		{"unused variations", `{"unknownVariations": []}`, ""},
+		{"unknown field", `{"hueStep": 10}`, "unknown field"},
+		{"unknown mode", `{"mode": "classic"}`, "unknown mode"},
+		{"unknown metric", `{"metric": "cmc"}`, "unknown metric"},
+		{"unknown curve", `{"lightLevels": {"curve": "cubic"}}`, "unknown light curve"},
+		{"missing gamma", `{"lightLevels": {"curve": "gamma"}}`, "light gamma 0 is not positive"},
+		{"negative inverted gamma", `{"invColors": {"curve": "gamma", "gamma": -1}}`, "inverted color gamma -1 is not positive"},
+		{"lstar fade", `{"invColors": {"curve": "lstar"}}`, ""},
+		{"torchlight", `{"lightLevels": {"curve": "points", "points": [{"level": 0, "scale": 0.1}, {"level": 31, "scale": 1}], "tint": "#ffc080"}}`, ""},
+		{"missing points", `{"lightLevels": {"curve": "points"}}`, "control points are missing"},
+		{"unsorted points", `{"invColors": {"curve": "points", "points": [{"level": 8, "scale": 1}, {"level": 2, "scale": 0}]}}`, "levels must increase"},
+		{"invalid tint", `{"lightLevels": {"tint": "orange"}}`, "invalid light tint"},
+		{"unknown blend space", `{"blendSpace": "hsl"}`, "unknown blend space"},
+		{"oklch hues", `{"hueSpace": "oklch"}`, ""},
+		{"unknown hue space", `{"hueSpace": "lab"}`, "unknown hue space"},
+		{"alpha ratios", `{"alphaBlend": {"ratios": [0.5]}}`, "alpha blend ratios"},
+		{"hue groups", `{"hueVariations": [{"count": 24, "step": 15}]}`, "describe 24 variations"},
+		{"variation kind", `{"unknownVariations": [{"kind": "sepia"}]}`, "unknown variation kind"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := DecodeRecipe(strings.NewReader(tt.recipe))
+			if err == nil {
+				_, err = r.Options()
+			}
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error is %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package pkg
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Identity returns the transform mapping every palette index to itself.
+func Identity() Transform {
+	t := Transform{}
+
+	for idx := range t {
+		t[idx] = uint8(idx)
+	}
+
+	return t
+}
+
+// Compose returns the transform applying t, then other. This is how the game stacks transforms,
+// e.g. a light level, then a hue variation: light.Compose(hue).
+func (t Transform) Compose(other Transform) Transform {
+	composed := Transform{}
+
+	for idx, mapped := range t {
+		composed[idx] = other[mapped]
+	}
+
+	return composed
+}
+
+// Equal tells whether both transforms map every palette index the same way.
+func (t Transform) Equal(other Transform) bool {
+	return t == other
+}
+
+// IsIdentity tells whether the transform maps every palette index to itself.
+func (t Transform) IsIdentity() bool {
+	return t == Identity()
+}
+
+// FixedPoints returns the sorted palette indices mapped to themselves.
+func (t Transform) FixedPoints() []int {
+	var fixed []int
+
+	for idx, mapped := range t {
+		if int(mapped) == idx {
+			fixed = append(fixed, idx)
+		}
+	}
+
+	return fixed
+}
+
+// Preimage returns the sorted palette indices mapped to dst.
+func (t Transform) Preimage(dst uint8) []int {
+	var src []int
+
+	for idx, mapped := range t {
+		if mapped == dst {
+			src = append(src, idx)
+		}
+	}
+
+	return src
+}
+
+// Apply maps the pixels of a paletted image in place, its palette is left untouched.
+func (t Transform) Apply(img *image.Paletted) {
+	bounds := img.Bounds()
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		offset := img.PixOffset(bounds.Min.X, y)
+		row := img.Pix[offset : offset+bounds.Dx()]
+
+		for x, idx := range row {
+			row[x] = t[idx]
+		}
+	}
+}
+
+// Recolor returns a copy of a paletted sprite recolored by a transform of the PL2, selected by
+// an element name such as "LightLevelVariations[12]", "HueVariations[30]" or "RedTones", see
+// ParseTransformID, or by a catalog name or colors.txt code, see ByName and ByCode. Pixels of
+// the sprite are indices into the base palette, its own palette is ignored. The copy keeps these
+// indices, and its palette is the base palette through the transform, with the transparent
+// index 0 of the game.
+func Recolor(sprite *image.Paletted, pl2 *PL2, selector string) (*image.Paletted, error) {
+	id, err := ParseTransformID(selector)
+	if err != nil {
+		e, found := ByName(selector)
+		if !found {
+			e, found = ByCode(selector)
+		}
+
+		if !found {
+			return nil, err
+		}
+
+		id = e.ID()
+	}
+
+	t, err := pl2.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pl2.BasePalette) != numPaletteColors {
+		const fmtErr = "could not recolor, base palette has %d colors, expected %d"
+		return nil, fmt.Errorf(fmtErr, len(pl2.BasePalette), numPaletteColors)
+	}
+
+	recolored := &image.Paletted{
+		Pix:     append([]uint8(nil), sprite.Pix...),
+		Stride:  sprite.Stride,
+		Rect:    sprite.Rect,
+		Palette: t.MakePaletteFromPalette(pl2.BasePalette),
+	}
+
+	recolored.Palette[0] = color.RGBA{}
+
+	return recolored, nil
+}
+
+// TransformID addresses a transform of a PL2, by section and flat index in the section.
+type TransformID struct {
+	Section SectionID
+	// Element is the flat index of the transform in its section, see Section.Index.
+	Element int
+}
+
+// String returns the element name of the transform, eg. "AlphaBlend[1][37]".
+func (id TransformID) String() string {
+	if id.Section < 0 || int(id.Section) >= NumSections {
+		return fmt.Sprintf("%v[%d]", id.Section, id.Element)
+	}
+
+	return id.Section.Section().ElementName(id.Element)
+}
+
+// ParseTransformID parses an element name such as "HueVariations[42]" or "RedTones", like
+// ParseElement, and makes sure it names a transform.
+func ParseTransformID(name string) (TransformID, error) {
+	section, flat, err := ParseElement(name)
+	if err != nil {
+		return TransformID{}, err
+	}
+
+	if !section.ID.IsTransforms() {
+		return TransformID{}, fmt.Errorf("%s is not a transform", name)
+	}
+
+	return TransformID{Section: section.ID, Element: flat}, nil
+}
+
+// Each calls fn with every transform of the PL2, in file order. Transforms modified by fn are
+// modified in the PL2. Sections which have not been allocated yield fewer transforms, see
+// Transforms.
+func (pl2 *PL2) Each(fn func(id TransformID, t *Transform)) {
+	for _, section := range layout {
+		for flat, t := range pl2.Transforms(section.ID) {
+			fn(TransformID{Section: section.ID, Element: flat}, t)
+		}
+	}
+}
+
+// Get returns a copy of the transform with the given identifier.
+func (pl2 *PL2) Get(id TransformID) (Transform, error) {
+	t, err := pl2.transform(id)
+	if err != nil {
+		return Transform{}, err
+	}
+
+	return *t, nil
+}
+
+// Set replaces the transform with the given identifier.
+func (pl2 *PL2) Set(id TransformID, t Transform) error {
+	dst, err := pl2.transform(id)
+	if err != nil {
+		return err
+	}
+
+	*dst = t
+
+	return nil
+}
+
+func (pl2 *PL2) transform(id TransformID) (*Transform, error) {
+	if id.Section < 0 || int(id.Section) >= NumSections || !id.Section.IsTransforms() {
+		return nil, fmt.Errorf("%v is not a transform section", id.Section)
+	}
+
+	section := id.Section.Section()
+	if id.Element < 0 || id.Element >= section.Count {
+		return nil, fmt.Errorf("index %d of %s out of range [0, %d)", id.Element, section.Name, section.Count)
+	}
+
+	transforms := pl2.Transforms(id.Section)
+	if id.Element >= len(transforms) {
+		return nil, fmt.Errorf("%v is missing, %s has %d transforms", id, section.Name, len(transforms))
+	}
+
+	return transforms[id.Element], nil
+}
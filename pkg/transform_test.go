@@ -0,0 +1,207 @@
+package pkg
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestTransform_Compose(t *testing.T) {
+	shift, half := Transform{}, Transform{}
+
+	for idx := range shift {
+		shift[idx] = uint8(idx + 1)
+		half[idx] = uint8(idx / 2)
+	}
+
+	tests := []struct {
+		name  string
+		first Transform
+		then  Transform
+		want  func(idx int) uint8
+	}{
+		{"identity first", Identity(), shift, func(idx int) uint8 { return shift[idx] }},
+		{"identity then", shift, Identity(), func(idx int) uint8 { return shift[idx] }},
+		{"shift then half", shift, half, func(idx int) uint8 { return uint8(idx+1) / 2 }},
+		{"half then shift", half, shift, func(idx int) uint8 { return uint8(idx/2 + 1) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.first.Compose(tt.then)
+
+			for idx := range got {
+				if want := tt.want(idx); got[idx] != want {
+					t.Fatalf("maps %d to %d, want %d", idx, got[idx], want)
+				}
+			}
+		})
+	}
+}
+
+func TestTransform_queries(t *testing.T) {
+	half := Transform{}
+	for idx := range half {
+		half[idx] = uint8(idx / 2)
+	}
+
+	if !Identity().IsIdentity() || half.IsIdentity() {
+		t.Error("IsIdentity is wrong")
+	}
+
+	if !half.Equal(half) || half.Equal(Identity()) {
+		t.Error("Equal is wrong")
+	}
+
+	if got, want := half.FixedPoints(), []int{0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("fixed points are %v, want %v", got, want)
+	}
+
+	if got, want := half.Preimage(3), []int{6, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("preimage of 3 is %v, want %v", got, want)
+	}
+
+	if got := half.Preimage(200); got != nil {
+		t.Errorf("preimage of 200 is %v, want none", got)
+	}
+}
+
+func TestTransform_Apply(t *testing.T) {
+	half := Transform{}
+	for idx := range half {
+		half[idx] = uint8(idx / 2)
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, 4, 2), color.Palette{color.Black})
+	copy(img.Pix, []uint8{0, 1, 2, 3, 4, 5, 6, 7})
+
+	// only the sub image is mapped
+	half.Apply(img.SubImage(image.Rect(1, 1, 3, 2)).(*image.Paletted))
+
+	if want := []uint8{0, 1, 2, 3, 4, 2, 3, 7}; !reflect.DeepEqual(img.Pix, want) {
+		t.Errorf("pixels are %v, want %v", img.Pix, want)
+	}
+}
+
+func TestRecolor(t *testing.T) {
+	p := Generate(webSafePalette(), DefaultGenerateOptions())
+
+	sprite := image.NewPaletted(image.Rect(0, 0, 16, 16), nil)
+	for idx := range sprite.Pix {
+		sprite.Pix[idx] = uint8(idx)
+	}
+
+	recolored, err := Recolor(sprite, p, "HueVariations[30]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, a := recolored.At(0, 0).RGBA(); a != 0 {
+		t.Error("index 0 should be transparent")
+	}
+
+	for idx := 1; idx < numPaletteColors; idx++ {
+		x, y := idx%16, idx/16
+
+		if got, want := recolored.At(x, y), p.BasePalette[p.HueVariations[30][idx]]; got != want {
+			t.Fatalf("index %d renders as %v, want %v", idx, got, want)
+		}
+	}
+
+	// catalog names and colors.txt codes select transforms too
+	for _, selector := range []string{"saturated-blue", "cblu"} {
+		got, err := Recolor(sprite, p, selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if want := p.BasePalette[p.HueVariations[107][1]]; got.At(1, 0) != want {
+			t.Errorf("%s renders index 1 as %v, want %v", selector, got.At(1, 0), want)
+		}
+	}
+
+	for _, selector := range []string{"TextColors", "HueVariations[111]", "Hues"} {
+		if _, err := Recolor(sprite, p, selector); err == nil {
+			t.Errorf("%s should not be applied", selector)
+		}
+	}
+}
+
+func TestPL2_Each(t *testing.T) {
+	p := &PL2{}
+	p.allocateTransforms()
+
+	// number every transform by its file order
+	var offsets []int64
+
+	p.Each(func(id TransformID, tr *Transform) {
+		tr[0], tr[1] = uint8(len(offsets)), uint8(len(offsets)>>8)
+		offsets = append(offsets, id.Section.Section().ElementOffset(id.Element))
+	})
+
+	if len(offsets) != NumTransforms {
+		t.Fatalf("Each visited %d transforms, want %d", len(offsets), NumTransforms)
+	}
+
+	data, err := ToBytes(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for order, offset := range offsets {
+		if order > 0 && offset <= offsets[order-1] {
+			t.Fatalf("transform %d comes before the previous one in the file", order)
+		}
+
+		if got := int(data[offset]) | int(data[offset+1])<<8; got != order {
+			t.Fatalf("transform %d of the file was visited as %d", order, got)
+		}
+	}
+}
+
+func TestPL2_GetSet(t *testing.T) {
+	p := &PL2{}
+	p.allocateTransforms()
+
+	id, err := ParseTransformID("AlphaBlend[1][37]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Set(id, Identity()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.AlphaBlend[1][37].IsIdentity() {
+		t.Error("Set did not replace AlphaBlend[1][37]")
+	}
+
+	if got, err := p.Get(id); err != nil || !got.IsIdentity() {
+		t.Errorf("Get(%v) = %v, %v", id, got, err)
+	}
+
+	invalid := []TransformID{
+		{Section: SectionBasePalette},
+		{Section: SectionHueVariations, Element: hueVariations},
+		{Section: SectionID(NumSections)},
+	}
+
+	for _, id := range invalid {
+		if _, err := p.Get(id); err == nil {
+			t.Errorf("Get(%v) should fail", id)
+		}
+	}
+
+	if _, err := (&PL2{}).Get(id); err == nil {
+		t.Error("Get should fail on transforms which have not been allocated")
+	}
+
+	if _, err := ParseTransformID("TextColors[1]"); err == nil {
+		t.Error("TextColors[1] is not a transform")
+	}
+
+	if got := (TransformID{Section: 99, Element: 3}).String(); got != "SectionID(99)[3]" {
+		t.Errorf("unknown section is named %s, want SectionID(99)[3]", got)
+	}
+}
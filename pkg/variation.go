@@ -0,0 +1,181 @@
+package pkg
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"sync"
+
+	color2 "github.com/lucasb-eyer/go-colorful"
+)
+
+// Variation defines a transform generated by a registered VariationFunc, in a slot of
+// UnknownVariations or in a hue group.
+type Variation struct {
+	// Kind names the generator of the transform, the transform is unused when empty.
+	Kind string `json:"kind,omitempty"`
+	// Color is a parameter of the generator, as a hex string such as "#ff8000".
+	Color string `json:"color,omitempty"`
+	// Amount is the strength of the generator, 1 when zero.
+	Amount float64 `json:"amount,omitempty"`
+}
+
+// Built-in variation kinds
+const (
+	// VariationNearBlack maps colors to grays darkened to near black.
+	VariationNearBlack = "near-black"
+	// VariationSolidTint blends colors toward Color by Amount, which maps every color to Color
+	// by default.
+	VariationSolidTint = "solid-tint"
+	// VariationDesaturate reduces the HSL saturation of colors by Amount, down to grays by
+	// default.
+	VariationDesaturate = "desaturate"
+	// VariationInvert inverts colors.
+	VariationInvert = "invert"
+)
+
+// VariationFunc generates a transform from the base palette. match finds the base palette color
+// closest to a computed color, under the metric and mode of the generation.
+type VariationFunc func(base color.Palette, match Matcher, v Variation) Transform
+
+// variationRegistry is shared by every generation
+var variationRegistry = struct {
+	sync.RWMutex
+	funcs map[string]VariationFunc
+}{
+	funcs: map[string]VariationFunc{
+		VariationNearBlack:  nearBlackVariation,
+		VariationSolidTint:  solidTintVariation,
+		VariationDesaturate: desaturateVariation,
+		VariationInvert:     invertVariation,
+	},
+}
+
+// RegisterVariation registers a variation generator under the given kind, so that mods can use
+// it in UnknownVariations and hue groups, usually from an init function. Kinds cannot be
+// registered twice.
+func RegisterVariation(kind string, fn VariationFunc) error {
+	if kind == "" || fn == nil {
+		return fmt.Errorf("could not register variation %q, kind and generator are required", kind)
+	}
+
+	variationRegistry.Lock()
+	defer variationRegistry.Unlock()
+
+	if _, found := variationRegistry.funcs[kind]; found {
+		return fmt.Errorf("could not register variation %q, already registered", kind)
+	}
+
+	variationRegistry.funcs[kind] = fn
+
+	return nil
+}
+
+// VariationKinds returns the registered variation kinds, built-in ones included.
+func VariationKinds() []string {
+	variationRegistry.RLock()
+	defer variationRegistry.RUnlock()
+
+	kinds := make([]string, 0, len(variationRegistry.funcs))
+
+	for kind := range variationRegistry.funcs {
+		kinds = append(kinds, kind)
+	}
+
+	sort.Strings(kinds)
+
+	return kinds
+}
+
+func variationFunc(kind string) (VariationFunc, bool) {
+	variationRegistry.RLock()
+	defer variationRegistry.RUnlock()
+
+	fn, found := variationRegistry.funcs[kind]
+
+	return fn, found
+}
+
+// check returns an error when the variation cannot be generated.
+func (v Variation) check() error {
+	if v.Kind == "" {
+		return nil
+	}
+
+	if _, found := variationFunc(v.Kind); !found {
+		return fmt.Errorf("unknown variation kind %q, expected one of %v", v.Kind, VariationKinds())
+	}
+
+	if v.Color != "" {
+		if _, err := color2.Hex(v.Color); err != nil {
+			return fmt.Errorf("invalid color %q for variation %q, %w", v.Color, v.Kind, err)
+		}
+	}
+
+	return nil
+}
+
+func (v Variation) amount() float64 {
+	if v.Amount == 0 {
+		return 1
+	}
+
+	return v.Amount
+}
+
+// mapColors builds a transform mapping each base palette color to the closest color of fn.
+func mapColors(base color.Palette, match Matcher, fn func(c color.Color) color.Color) Transform {
+	t := Transform{}
+
+	for palIdx := range t {
+		t[palIdx] = uint8(match.Index(fn(base[palIdx])))
+	}
+
+	return t
+}
+
+func nearBlackVariation(base color.Palette, match Matcher, _ Variation) Transform {
+	// Near-full black, rgb(4, 4, 4)
+	return mapColors(base, match, func(c color.Color) color.Color {
+		H, S, L := rgba2hsl(c).Hsl()
+
+		S = 0
+		L /= 16
+
+		return color2.Hsl(H, S, L+0.015)
+	})
+}
+
+func solidTintVariation(base color.Palette, match Matcher, v Variation) Transform {
+	// colors are black without a tint color
+	tint, _ := color2.Hex(v.Color)
+	amount := v.amount()
+
+	return mapColors(base, match, func(c color.Color) color.Color {
+		return toColorful(c).BlendRgb(tint, amount).Clamped()
+	})
+}
+
+func desaturateVariation(base color.Palette, match Matcher, v Variation) Transform {
+	amount := v.amount()
+
+	return mapColors(base, match, func(c color.Color) color.Color {
+		h, s, l := rgba2hsl(c).Hsl()
+
+		return color2.Hsl(h, s*math.Max(0, 1-amount), l)
+	})
+}
+
+func invertVariation(base color.Palette, match Matcher, _ Variation) Transform {
+	return mapColors(base, match, func(c color.Color) color.Color {
+		r, g, b, _ := c.RGBA()
+
+		return color.RGBA{
+			R: math.MaxUint8 - uint8(r>>8),
+			G: math.MaxUint8 - uint8(g>>8),
+			B: math.MaxUint8 - uint8(b>>8),
+			A: math.MaxUint8,
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestRegisterVariation(t *testing.T) {
+	const kind = "test-first-color"
+
+	first := func(base color.Palette, match Matcher, v Variation) Transform {
+		return Transform{}
+	}
+
+	if err := RegisterVariation(kind, first); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { unregisterVariation(kind) })
+
+	if err := RegisterVariation(kind, first); err == nil {
+		t.Error("registering a kind twice should fail")
+	}
+
+	if err := RegisterVariation(VariationInvert, first); err == nil {
+		t.Error("registering a built-in kind should fail")
+	}
+
+	if err := (Variation{Kind: kind}).check(); err != nil {
+		t.Error(err)
+	}
+}
+
+// unregisterVariation removes a kind registered by a test, so that the test can run again.
+func unregisterVariation(kind string) {
+	variationRegistry.Lock()
+	defer variationRegistry.Unlock()
+
+	delete(variationRegistry.funcs, kind)
+}
+
+func TestVariations(t *testing.T) {
+	p := webSafePalette()
+	index := func(r, g, b uint8) uint8 {
+		return uint8(p.Index(color.RGBA{R: r, G: g, B: b, A: 0xFF}))
+	}
+
+	white, black, red := index(0xFF, 0xFF, 0xFF), index(0, 0, 0), index(0xFF, 0, 0)
+
+	opts := DefaultGenerateOptions()
+	opts.Sections = []SectionID{SectionUnknownVariations, SectionHueVariations}
+	opts.UnknownVariations = []Variation{
+		{Kind: VariationNearBlack},
+		{Kind: VariationSolidTint, Color: "#ff0000"},
+		{Kind: VariationDesaturate},
+		{Kind: VariationInvert},
+	}
+
+	// the spare full black hue slot
+	opts.HueGroups = DefaultHueGroups(opts)
+	opts.HueGroups[6] = HueGroup{Count: 1, Variation: &Variation{Kind: VariationInvert}}
+
+	src := &PL2{BasePalette: p}
+	src.allocateTransforms()
+
+	pl2, err := NewGenerator(opts).Regenerate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		transform Transform
+		from, to  uint8
+	}{
+		{"near black", pl2.UnknownVariations[0], white, index(20, 20, 20)},
+		{"solid tint", pl2.UnknownVariations[1], white, red},
+		{"desaturate", pl2.UnknownVariations[2], red, index(0x80, 0x80, 0x80)},
+		{"invert", pl2.UnknownVariations[3], white, black},
+		{"unused", pl2.UnknownVariations[4], white, 0},
+		{"hue slot", pl2.HueVariations[98], black, white},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.transform[tt.from]; got != tt.to {
+				t.Errorf("maps %v to %v, want %v", p[tt.from], p[got], p[tt.to])
+			}
+		})
+	}
+}
+
+func TestGenerator_invalidVariations(t *testing.T) {
+	tests := []struct {
+		name string
+		opts func(opts *GenerateOptions)
+	}{
+		{"first group tolerance", func(opts *GenerateOptions) {
+			opts.HueGroups = DefaultHueGroups(*opts)
+			opts.HueGroups[0].Tolerance = 45
+		}},
+		{"hue group count", func(opts *GenerateOptions) {
+			opts.HueGroups = DefaultHueGroups(*opts)[1:]
+		}},
+		{"unknown kind", func(opts *GenerateOptions) {
+			opts.UnknownVariations = []Variation{{Kind: "unknown"}}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultGenerateOptions()
+			tt.opts(&opts)
+
+			if _, err := NewGenerator(opts).Generate(nil, nil); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package pkg
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+)
+
+// PL2View is a read-only view over encoded PL2 data. It gives random access to single transforms
+// and colors without decoding the whole file, and is safe for concurrent use as long as the
+// underlying data is not modified.
+type PL2View struct {
+	data []byte      // set when the view is backed by a byte slice
+	r    io.ReaderAt // set otherwise
+}
+
+// NewView returns a view over the given PL2 data, eg. a memory-mapped file. The data is not copied,
+// and must not be modified while the view is in use.
+func NewView(data []byte) (*PL2View, error) {
+	if err := validateSize(int64(len(data))); err != nil {
+		return nil, err
+	}
+
+	return &PL2View{data: data}, nil
+}
+
+// NewViewReaderAt returns a view reading PL2 data of the given size from r. Reads never go past
+// size.
+func NewViewReaderAt(r io.ReaderAt, size int64) (*PL2View, error) {
+	if err := validateSize(size); err != nil {
+		return nil, err
+	}
+
+	return &PL2View{r: io.NewSectionReader(r, 0, size)}, nil
+}
+
+// validateSize makes sure that data of the given size holds every section of a PL2.
+func validateSize(size int64) error {
+	if size >= FileSize {
+		return nil
+	}
+
+	for _, section := range layout {
+		if size >= section.End() {
+			continue
+		}
+
+		idx := int((size - section.Offset) / int64(section.ElementSize))
+
+		return &TruncatedError{
+			Section: section.Name,
+			Index:   section.Index(idx),
+			Size:    section.ElementSize,
+			Offset:  section.ElementOffset(idx),
+			End:     size,
+		}
+	}
+
+	return nil
+}
+
+// locate returns the section and file offset of an element.
+func locate(id SectionID, index int) (Section, int64, error) {
+	if id < 0 || int(id) >= NumSections {
+		return Section{}, 0, fmt.Errorf("unknown section %v", id)
+	}
+
+	section := layout[id]
+
+	if index < 0 || index >= section.Count {
+		return Section{}, 0, fmt.Errorf("index %d of %s out of range [0, %d)", index, section.Name, section.Count)
+	}
+
+	return section, section.ElementOffset(index), nil
+}
+
+// readAt reads len(buf) bytes at the given offset, which lies inside the given element. As
+// allowed by io.ReaderAt, a read ending the data may return io.EOF along with every byte.
+func (v *PL2View) readAt(buf []byte, offset int64, section Section, index int) error {
+	if n, err := v.r.ReadAt(buf, offset); err != nil && !(n == len(buf) && err == io.EOF) {
+		return &DecodeError{
+			Section: section.Name,
+			Index:   section.Index(index),
+			Offset:  offset,
+			Err:     err,
+		}
+	}
+
+	return nil
+}
+
+// element returns the bytes of an element of a section. When the view is backed by a byte slice,
+// the returned bytes share its memory.
+func (v *PL2View) element(id SectionID, index int) ([]byte, error) {
+	section, offset, err := locate(id, index)
+	if err != nil {
+		return nil, err
+	}
+
+	end := offset + int64(section.ElementSize)
+
+	if v.data != nil {
+		return v.data[offset:end:end], nil
+	}
+
+	buf := make([]byte, section.ElementSize)
+
+	if err := v.readAt(buf, offset, section, index); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+// TransformBytes returns the 256 palette indices of the transform at the given flat index of a
+// section, see Section.FlatIndex. No copy is made when the view is backed by a byte slice.
+func (v *PL2View) TransformBytes(id SectionID, index int) ([]byte, error) {
+	if !id.IsTransforms() {
+		return nil, fmt.Errorf("%v does not hold transforms", id)
+	}
+
+	return v.element(id, index)
+}
+
+// Transform returns a copy of the transform at the given flat index of a section.
+func (v *PL2View) Transform(id SectionID, index int) (Transform, error) {
+	var t Transform
+
+	data, err := v.TransformBytes(id, index)
+	if err != nil {
+		return t, err
+	}
+
+	copy(t[:], data)
+
+	return t, nil
+}
+
+// Lookup returns the palette index that the transform at the given flat index of a section maps
+// the palette index to.
+func (v *PL2View) Lookup(id SectionID, index int, paletteIndex uint8) (uint8, error) {
+	if !id.IsTransforms() {
+		return 0, fmt.Errorf("%v does not hold transforms", id)
+	}
+
+	section, offset, err := locate(id, index)
+	if err != nil {
+		return 0, err
+	}
+
+	offset += int64(paletteIndex)
+
+	if v.data != nil {
+		return v.data[offset], nil
+	}
+
+	buf := []byte{0}
+
+	if err := v.readAt(buf, offset, section, index); err != nil {
+		return 0, err
+	}
+
+	return buf[0], nil
+}
+
+// Color returns a color of the base palette or of the text colors.
+func (v *PL2View) Color(id SectionID, index int) (color.RGBA, error) {
+	if id.IsTransforms() {
+		return color.RGBA{}, fmt.Errorf("%v does not hold colors", id)
+	}
+
+	data, err := v.element(id, index)
+	if err != nil {
+		return color.RGBA{}, err
+	}
+
+	return color.RGBA{R: data[0], G: data[1], B: data[2], A: math.MaxUint8}, nil
+}
+
+// BasePalette returns a copy of the base palette.
+func (v *PL2View) BasePalette() (color.Palette, error) {
+	return v.palette(SectionBasePalette)
+}
+
+// TextColors returns a copy of the text colors.
+func (v *PL2View) TextColors() (color.Palette, error) {
+	return v.palette(SectionTextColors)
+}
+
+func (v *PL2View) palette(id SectionID) (color.Palette, error) {
+	p := make(color.Palette, layout[id].Count)
+
+	for idx := range p {
+		c, err := v.Color(id, idx)
+		if err != nil {
+			return nil, err
+		}
+
+		p[idx] = &c
+	}
+
+	return p, nil
+}
+
+// Decode decodes the whole PL2.
+func (v *PL2View) Decode() (*PL2, error) {
+	if v.data != nil {
+		return FromBytes(v.data)
+	}
+
+	return Decode(io.NewSectionReader(v.r, 0, FileSize))
+}
@@ -0,0 +1,122 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"io"
+	"testing"
+)
+
+func TestPL2View(t *testing.T) {
+	data := randomPL2Bytes(1)
+
+	pl2, err := FromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byteView, err := NewView(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readerView, err := NewViewReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, view := range []*PL2View{byteView, readerView} {
+		for _, section := range Layout() {
+			if !section.ID.IsTransforms() {
+				continue
+			}
+
+			for idx, want := range pl2.Transforms(section.ID) {
+				got, err := view.Transform(section.ID, idx)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if got != *want {
+					t.Fatalf("%s differs from the decoded transform", section.ElementName(idx))
+				}
+
+				lookup, err := view.Lookup(section.ID, idx, 200)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if lookup != want[200] {
+					t.Fatalf("%s maps 200 to %d, want %d", section.ElementName(idx), lookup, want[200])
+				}
+			}
+		}
+
+		base, err := view.BasePalette()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for idx := range base {
+			if color.RGBAModel.Convert(base[idx]) != color.RGBAModel.Convert(pl2.BasePalette[idx]) {
+				t.Fatalf("base palette color %d differs from the decoded color", idx)
+			}
+		}
+	}
+
+	if _, err := byteView.Transform(SectionHueVariations, hueVariations); err == nil {
+		t.Error("expected an error for an out of range transform")
+	}
+
+	if _, err := byteView.Transform(SectionBasePalette, 0); err == nil {
+		t.Error("expected an error for a transform of a color section")
+	}
+}
+
+func TestNewView_truncated(t *testing.T) {
+	data := randomPL2Bytes(1)
+	hue := SectionHueVariations.Section()
+
+	_, err := NewView(data[:hue.ElementOffset(42)+10])
+
+	var truncated *TruncatedError
+	if !errors.As(err, &truncated) {
+		t.Fatalf("expected a TruncatedError, got %v", err)
+	}
+
+	if truncated.Element() != "HueVariations[42]" {
+		t.Errorf("truncated element is %s, want HueVariations[42]", truncated.Element())
+	}
+}
+
+// eofReaderAt returns io.EOF along with the last bytes of the data, as io.ReaderAt allows.
+type eofReaderAt struct {
+	*bytes.Reader
+}
+
+func (r eofReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.Reader.ReadAt(p, off)
+	if err == nil && off+int64(n) == r.Size() {
+		err = io.EOF
+	}
+
+	return n, err
+}
+
+func TestNewViewReaderAt_eof(t *testing.T) {
+	data := randomPL2Bytes(1)
+
+	view, err := NewViewReaderAt(eofReaderAt{bytes.NewReader(data)}, int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := view.Transform(SectionTextColorShifts, textShifts-1); err != nil {
+		t.Errorf("reading the last transform failed, %v", err)
+	}
+
+	if _, err := view.TextColors(); err != nil {
+		t.Errorf("reading the text colors failed, %v", err)
+	}
+}